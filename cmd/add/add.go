@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/cli/go-gh"
 	"github.com/cli/go-gh/pkg/api"
@@ -21,6 +22,7 @@ type cmdFlags struct {
 	token    string
 	hostname string
 	fileName string
+	invite   bool
 	debug    bool
 }
 
@@ -52,12 +54,16 @@ func NewCmdAdd() *cobra.Command {
 				authToken = t
 			}
 
+			// debug logging is also written to stderr, so it is not redrawn over
+			progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
 			restClient, err = gh.RESTClient(&api.ClientOptions{
 				Headers: map[string]string{
 					"Accept": "application/vnd.github+json",
 				},
 				Host:      cmdFlags.hostname,
 				AuthToken: authToken,
+				Transport: progress.Transport(nil),
 			})
 
 			if err != nil {
@@ -71,6 +77,7 @@ func NewCmdAdd() *cobra.Command {
 				},
 				Host:      cmdFlags.hostname,
 				AuthToken: authToken,
+				Transport: progress.Transport(nil),
 			})
 
 			if err != nil {
@@ -80,7 +87,10 @@ func NewCmdAdd() *cobra.Command {
 
 			owner := args[0]
 
-			return runCmdAdd(owner, &cmdFlags, utils.NewAPIGetter(gqlClient, restClient))
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetProgress(progress)
+
+			return runCmdAdd(owner, &cmdFlags, g)
 		},
 	}
 
@@ -88,7 +98,8 @@ func NewCmdAdd() *cobra.Command {
 
 	addCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
 	addCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
-	addCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV file to create access from (required)")
+	addCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV or XLSX file to create access from, or - for stdin (required)")
+	addCmd.Flags().BoolVarP(&cmdFlags.invite, "invite-to-org", "", false, "Invite users who are not organization members when adding them to a team")
 	addCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
 	addCmd.MarkFlagRequired("from-file")
 
@@ -99,11 +110,25 @@ func runCmdAdd(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
 	var collabData [][]string
 	var importRepoCollabList []data.ImportedRepoCollab
 
-	if len(cmdFlags.fileName) > 0 {
-		f, err := os.Open(cmdFlags.fileName)
+	if utils.IsXLSXFile(cmdFlags.fileName) {
+		var err error
+		collabData, err = utils.ReadXLSXRecords(cmdFlags.fileName)
+		zap.S().Debugf("Reading in all rows from xlsx workbook")
+		if err != nil {
+			zap.S().Errorf("Error arose reading assignments from xlsx workbook")
+			return err
+		}
+		importRepoCollabList, err = g.CreateRepoCollaboratorsList(collabData)
+		if err != nil {
+			zap.S().Errorf("Error arose reading the columns of %s", cmdFlags.fileName)
+			return err
+		}
+	} else if len(cmdFlags.fileName) > 0 {
+		f, err := utils.OpenInput(cmdFlags.fileName)
 		zap.S().Debugf("Opening up file %s", cmdFlags.fileName)
 		if err != nil {
 			zap.S().Errorf("Error arose opening repository collaborators csv file")
+			return err
 		}
 		// remember to close the file at the end of the program
 		defer f.Close()
@@ -113,13 +138,39 @@ func runCmdAdd(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
 		zap.S().Debugf("Reading in all lines from csv file")
 		if err != nil {
 			zap.S().Errorf("Error arose reading assignments from csv file")
+			return err
+		}
+		importRepoCollabList, err = g.CreateRepoCollaboratorsList(collabData)
+		if err != nil {
+			zap.S().Errorf("Error arose reading the columns of %s", cmdFlags.fileName)
+			return err
 		}
-		importRepoCollabList = g.CreateRepoCollaboratorsList(collabData)
 	} else {
 		zap.S().Errorf("Error arose identifying users to add")
 	}
+	if err := checkOrgMembers(owner, cmdFlags, importRepoCollabList, g); err != nil {
+		return err
+	}
+
 	zap.S().Debugf("Determining permissions to create")
+	g.Progress().Start("Adding access", "rows", len(importRepoCollabList))
 	for _, importRepoCollab := range importRepoCollabList {
+		g.Progress().Step()
+		if len(importRepoCollab.Team) > 0 {
+			role, err := utils.TeamMembershipRole(importRepoCollab.TeamRole)
+			if err != nil {
+				zap.S().Errorf("Error arose adding user %s to team %s: %v", importRepoCollab.Username, importRepoCollab.Team, err)
+			} else {
+				zap.S().Debugf("Adding user %s to team %s with role %s", importRepoCollab.Username, importRepoCollab.Team, role)
+				err = g.AddTeamMembership(owner, importRepoCollab.Team, importRepoCollab.Username, role)
+				if err != nil {
+					zap.S().Errorf("Error arose adding user %s to team %s", importRepoCollab.Username, importRepoCollab.Team)
+				}
+			}
+		}
+		if len(importRepoCollab.RepositoryName) == 0 {
+			continue
+		}
 		zap.S().Debugf("Adding user %s to repo %s", importRepoCollab.Username, importRepoCollab.RepositoryName)
 		repoPermObject := utils.CreateRepoPermData(importRepoCollab.Permission)
 		assignRepo, err := json.Marshal(repoPermObject)
@@ -135,7 +186,39 @@ func runCmdAdd(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
 			zap.S().Errorf("Error arose creating permission for user %s  and repo %s", importRepoCollab.Username, importRepoCollab.RepositoryName)
 		}
 	}
+	g.Progress().Done()
 
 	fmt.Printf("Successfully created repository assignments for repository collaborators in: %s.", owner)
 	return nil
 }
+
+// checkOrgMembers refuses to add users who are not organization members to
+// teams, as doing so sends them an invitation to join the organization,
+// unless --invite-to-org is specified.
+func checkOrgMembers(owner string, cmdFlags *cmdFlags, importRepoCollabList []data.ImportedRepoCollab, g *utils.APIGetter) error {
+	if cmdFlags.invite {
+		return nil
+	}
+	checked := make(map[string]bool)
+	var outsiders []string
+	for _, importRepoCollab := range importRepoCollabList {
+		username := strings.ToLower(importRepoCollab.Username)
+		if len(importRepoCollab.Team) == 0 || checked[username] {
+			continue
+		}
+		checked[username] = true
+		zap.S().Debugf("Checking organization membership of %s", importRepoCollab.Username)
+		membership, err := g.GetOrgMembership(owner, importRepoCollab.Username)
+		if err != nil {
+			zap.S().Errorf("Error arose checking organization membership of %s", importRepoCollab.Username)
+			return err
+		}
+		if membership == nil {
+			outsiders = append(outsiders, importRepoCollab.Username)
+		}
+	}
+	if len(outsiders) > 0 {
+		return fmt.Errorf("not adding %s to teams, as they are not members of %s and would be invited to the organization, specify --invite-to-org to invite them", strings.Join(outsiders, ", "), owner)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package browse
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"golang.org/x/term"
+)
+
+type cmdFlags struct {
+	token       string
+	hostname    string
+	usernames   []string
+	filter      utils.RepoFilter
+	resultsFile string
+	debug       bool
+}
+
+func NewCmdBrowse() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	browseCmd := &cobra.Command{
+		Use:   "browse [flags] <organization>",
+		Short: "Browse and edit repository collaborator access interactively.",
+		Long:  "Browse repository collaborator access by user or by repository in a terminal UI, changing permissions or revoking grants and reviewing the pending changes before applying them.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(browseCmd *cobra.Command, args []string) error {
+			var err error
+			var gqlClient api.GQLClient
+			var restClient api.RESTClient
+
+			if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+				return errors.New("browse must be run in a terminal, use list, add and remove to script changes")
+			}
+
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			if err = cmdFlags.filter.Compile(); err != nil {
+				return err
+			}
+
+			if cmdFlags.token != "" {
+				authToken = cmdFlags.token
+			} else {
+				t, _ := auth.TokenForHost(cmdFlags.hostname)
+				authToken = t
+			}
+
+			progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
+			restClient, err = gh.RESTClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving rest client")
+				return err
+			}
+
+			gqlClient, err = gh.GQLClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github.hawkgirl-preview+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving graphql client")
+				return err
+			}
+
+			owner := args[0]
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetProgress(progress)
+
+			return runCmdBrowse(owner, &cmdFlags, g)
+		},
+	}
+
+	resultsFileDefault := fmt.Sprintf("RepoCollaboratorsBrowse-%s.csv", time.Now().Format("20060102150405"))
+
+	// Configure flags for command
+
+	browseCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	browseCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	browseCmd.Flags().StringArrayVarP(&cmdFlags.usernames, "username", "u", nil, "Username of a repo collaborator to browse (repeatable)")
+	browseCmd.Flags().StringVarP(&cmdFlags.filter.Repo, "repo", "", "", "Only browse repositories matching a glob, or a regular expression between slashes")
+	browseCmd.Flags().StringVarP(&cmdFlags.resultsFile, "results-file", "r", resultsFileDefault, "Name of file to write CSV results of applied changes to")
+	browseCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return browseCmd
+}
+
+func runCmdBrowse(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	rows, err := g.GetReportRows(owner, utils.ReportOptions{
+		Usernames: cmdFlags.usernames,
+		Explain:   true,
+		Filter:    cmdFlags.filter,
+	})
+	if err != nil {
+		zap.S().Errorf("Error arose gathering repository collaborator access")
+		return err
+	}
+	if len(rows) == 0 {
+		fmt.Printf("No repository collaborator access found in %s.", owner)
+		return nil
+	}
+
+	// the status line would be drawn over the UI
+	g.SetProgress(nil)
+	b := newBrowser(owner, rows, g)
+	if err = b.run(os.Stdin, os.Stdout); err != nil {
+		return err
+	}
+	if len(b.results) == 0 {
+		fmt.Println("No changes were applied.")
+		return nil
+	}
+
+	resultsWriter, err := os.Create(cmdFlags.resultsFile)
+	if err != nil {
+		return err
+	}
+	defer resultsWriter.Close()
+	csvWriter := csv.NewWriter(resultsWriter)
+	err = csvWriter.Write([]string{"RepositoryName", "Username", "AccessLevel", "Action", "NewAccessLevel", "Status", "Error"})
+	if err != nil {
+		zap.S().Error("Error raised in writing output", zap.Error(err))
+	}
+	failed := 0
+	for _, r := range b.results {
+		if r.status == "failed" {
+			failed++
+		}
+		err = csvWriter.Write([]string{r.repository, r.username, r.accessLevel, r.action, r.newAccessLevel, r.status, r.errMessage})
+		if err != nil {
+			zap.S().Error("Error raised in writing output", zap.Error(err))
+		}
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Applied %d of %d changes to repository collaborator access in %s. Results written to %s.", len(b.results)-failed, len(b.results), owner, cmdFlags.resultsFile)
+	return nil
+}
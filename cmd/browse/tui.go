@@ -0,0 +1,803 @@
+package browse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"go.uber.org/zap"
+	"golang.org/x/term"
+)
+
+const (
+	actionRevoke = "revoke"
+	actionChange = "change"
+)
+
+// permissionKeys are the keys choosing the new permission of the selected
+// grants.
+var permissionKeys = map[string]string{
+	"r": "READ",
+	"t": "TRIAGE",
+	"w": "WRITE",
+	"m": "MAINTAIN",
+	"a": "ADMIN",
+}
+
+// grant is a report row with the change pending for it, if any.
+type grant struct {
+	data.ReportRow
+	selected       bool
+	action         string
+	newAccessLevel string
+	errMessage     string
+	// unverified is why the access of an applied change could not be read
+	// again, leaving what the grant shows unconfirmed.
+	unverified string
+}
+
+// direct reports whether the grant can be changed through the repository
+// collaborators API, rather than only through a team or the organization.
+func (gr *grant) direct() bool {
+	return gr.DirectAccess != "" || (gr.TeamAccess == "" && gr.OrganizationAccess == "")
+}
+
+func (gr *grant) source() string {
+	var sources []string
+	if gr.DirectAccess != "" {
+		sources = append(sources, "direct")
+	}
+	if gr.TeamAccess != "" {
+		sources = append(sources, "team "+gr.TeamAccess)
+	}
+	if gr.OrganizationAccess != "" {
+		sources = append(sources, "org "+gr.OrganizationAccess)
+	}
+	return strings.Join(sources, ", ")
+}
+
+func (gr *grant) pendingText() string {
+	var text string
+	switch gr.action {
+	case actionRevoke:
+		text = "-> revoke"
+	case actionChange:
+		text = "-> " + gr.newAccessLevel
+	}
+	if gr.errMessage != "" {
+		text += " (failed: " + gr.errMessage + ")"
+	}
+	if gr.unverified != "" {
+		text += " (unverified: " + gr.unverified + ")"
+	}
+	return text
+}
+
+// result is the outcome of applying a pending change, for the results file.
+type result struct {
+	repository     string
+	username       string
+	accessLevel    string
+	action         string
+	newAccessLevel string
+	status         string
+	errMessage     string
+}
+
+type view int
+
+const (
+	viewGroups view = iota
+	viewGrants
+	viewPending
+)
+
+// mode is what the keys typed in the current view are for.
+type mode int
+
+const (
+	modeNavigate mode = iota
+	modeSearch
+	modePermission
+	modeConfirmApply
+	modeConfirmQuit
+)
+
+type group struct {
+	name    string
+	grants  int
+	pending int
+}
+
+// browser is the state of the terminal UI, listing users or repositories,
+// the grants of one of them, or the pending changes.
+type browser struct {
+	owner    string
+	g        *utils.APIGetter
+	grants   []*grant
+	byRepo   bool
+	view     view
+	previous view
+	mode     mode
+	group    string
+	query    string
+	cursor   int
+	offset   int
+	message  string
+	results  []result
+	out      *os.File
+}
+
+func newBrowser(owner string, rows []data.ReportRow, g *utils.APIGetter) *browser {
+	b := &browser{owner: owner, g: g}
+	for _, row := range rows {
+		// team membership rows are not repository grants
+		if len(row.Team) > 0 {
+			continue
+		}
+		b.grants = append(b.grants, &grant{ReportRow: row})
+	}
+	return b
+}
+
+// run draws the UI on out and handles the keys typed on in until the user
+// quits.
+func (b *browser) run(in *os.File, out *os.File) error {
+	b.out = out
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(in.Fd()), state) // nolint:errcheck
+
+	// log lines would be written over the UI, so errors are only shown in it
+	defer zap.ReplaceGlobals(zap.NewNop())()
+
+	// use the alternate screen, hiding the cursor, so the shell is restored
+	// on exit
+	fmt.Fprint(out, "\033[?1049h\033[?25l")
+	defer fmt.Fprint(out, "\033[?25h\033[?1049l")
+
+	buf := make([]byte, 256)
+	for {
+		b.draw()
+		n, err := in.Read(buf)
+		if err != nil {
+			return err
+		}
+		if b.handleKey(parseKey(buf[:n])) {
+			return nil
+		}
+	}
+}
+
+func parseKey(input []byte) string {
+	switch s := string(input); s {
+	case "\x1b[A", "\x1bOA":
+		return "up"
+	case "\x1b[B", "\x1bOB":
+		return "down"
+	case "\x1b[C", "\x1bOC":
+		return "right"
+	case "\x1b[D", "\x1bOD":
+		return "left"
+	case "\x1b[5~":
+		return "pgup"
+	case "\x1b[6~":
+		return "pgdown"
+	case "\x1b[H", "\x1b[1~", "\x1bOH":
+		return "home"
+	case "\x1b[F", "\x1b[4~", "\x1bOF":
+		return "end"
+	case "\x1b":
+		return "esc"
+	case "\r", "\n":
+		return "enter"
+	case "\x7f", "\b":
+		return "backspace"
+	case "\t":
+		return "tab"
+	case "\x03":
+		return "ctrl+c"
+	case " ":
+		return "space"
+	default:
+		return s
+	}
+}
+
+func (b *browser) groupKey(gr *grant) string {
+	if b.byRepo {
+		return gr.RepositoryName
+	}
+	return gr.Username
+}
+
+func (b *browser) itemName(gr *grant) string {
+	if b.byRepo {
+		return gr.Username
+	}
+	return gr.RepositoryName
+}
+
+func (b *browser) matches(names ...string) bool {
+	query := strings.ToLower(b.query)
+	for _, name := range names {
+		if strings.Contains(strings.ToLower(name), query) {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *browser) groups() []group {
+	byName := make(map[string]*group)
+	var groups []*group
+	for _, gr := range b.grants {
+		name := b.groupKey(gr)
+		entry, ok := byName[name]
+		if !ok {
+			entry = &group{name: name}
+			byName[name] = entry
+			groups = append(groups, entry)
+		}
+		entry.grants++
+		if gr.action != "" {
+			entry.pending++
+		}
+	}
+	var visible []group
+	for _, entry := range groups {
+		if b.matches(entry.name) {
+			visible = append(visible, *entry)
+		}
+	}
+	sort.SliceStable(visible, func(i, j int) bool {
+		return strings.ToLower(visible[i].name) < strings.ToLower(visible[j].name)
+	})
+	return visible
+}
+
+// visibleGrants lists the grants of the open group, or the pending changes,
+// matching the search.
+func (b *browser) visibleGrants() []*grant {
+	var visible []*grant
+	for _, gr := range b.grants {
+		switch b.view {
+		case viewGrants:
+			if b.groupKey(gr) == b.group && b.matches(b.itemName(gr)) {
+				visible = append(visible, gr)
+			}
+		case viewPending:
+			if gr.action != "" && b.matches(gr.Username, gr.RepositoryName) {
+				visible = append(visible, gr)
+			}
+		}
+	}
+	sort.SliceStable(visible, func(i, j int) bool {
+		if b.view == viewPending && !strings.EqualFold(visible[i].Username, visible[j].Username) {
+			return strings.ToLower(visible[i].Username) < strings.ToLower(visible[j].Username)
+		}
+		return strings.ToLower(b.itemName(visible[i])) < strings.ToLower(b.itemName(visible[j]))
+	})
+	return visible
+}
+
+func (b *browser) pendingCount() int {
+	pending := 0
+	for _, gr := range b.grants {
+		if gr.action != "" {
+			pending++
+		}
+	}
+	return pending
+}
+
+func (b *browser) itemCount() int {
+	if b.view == viewGroups {
+		return len(b.groups())
+	}
+	return len(b.visibleGrants())
+}
+
+// targets are the selected grants in view, or the grant under the cursor
+// when none are selected.
+func (b *browser) targets() []*grant {
+	visible := b.visibleGrants()
+	var selected []*grant
+	for _, gr := range visible {
+		if gr.selected {
+			selected = append(selected, gr)
+		}
+	}
+	if len(selected) == 0 && b.cursor < len(visible) {
+		selected = append(selected, visible[b.cursor])
+	}
+	return selected
+}
+
+func (b *browser) open(v view) {
+	if v == viewPending && b.view != viewPending {
+		b.previous = b.view
+	}
+	b.view, b.cursor, b.offset, b.query = v, 0, 0, ""
+	if v == viewGroups {
+		for i, entry := range b.groups() {
+			if entry.name == b.group {
+				b.cursor = i
+			}
+		}
+	}
+}
+
+func (b *browser) moveCursor(delta int) {
+	b.cursor += delta
+	if count := b.itemCount(); b.cursor >= count {
+		b.cursor = count - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+}
+
+// handleKey updates the UI for a key, reporting whether to quit.
+func (b *browser) handleKey(key string) bool {
+	switch b.mode {
+	case modeSearch:
+		switch key {
+		case "esc":
+			b.query, b.mode = "", modeNavigate
+		case "enter":
+			b.mode = modeNavigate
+		case "backspace":
+			if len(b.query) > 0 {
+				b.query = b.query[:len(b.query)-1]
+			}
+		case "space":
+			b.query += " "
+		default:
+			if isText(key) {
+				b.query += key
+			}
+		}
+		b.cursor, b.offset = 0, 0
+		return false
+	case modePermission:
+		b.mode = modeNavigate
+		if level, ok := permissionKeys[key]; ok {
+			b.stage(actionChange, level)
+		} else {
+			b.message = ""
+		}
+		return false
+	case modeConfirmApply:
+		b.mode = modeNavigate
+		if key == "y" || key == "Y" {
+			b.apply()
+		} else {
+			b.message = "No changes were applied."
+		}
+		return false
+	case modeConfirmQuit:
+		b.mode = modeNavigate
+		return key == "y" || key == "Y"
+	}
+
+	b.message = ""
+	switch key {
+	case "ctrl+c":
+		return true
+	case "q":
+		if b.pendingCount() > 0 {
+			b.mode = modeConfirmQuit
+			return false
+		}
+		return true
+	case "up", "k":
+		b.moveCursor(-1)
+	case "down", "j":
+		b.moveCursor(1)
+	case "pgup":
+		b.moveCursor(-b.bodyHeight())
+	case "pgdown":
+		b.moveCursor(b.bodyHeight())
+	case "home", "g":
+		b.moveCursor(-b.itemCount())
+	case "end", "G":
+		b.moveCursor(b.itemCount())
+	case "/":
+		b.mode = modeSearch
+	case "c":
+		b.open(viewPending)
+	default:
+		switch b.view {
+		case viewGroups:
+			b.handleGroupsKey(key)
+		case viewGrants:
+			b.handleGrantsKey(key)
+		case viewPending:
+			b.handlePendingKey(key)
+		}
+	}
+	return false
+}
+
+func (b *browser) handleGroupsKey(key string) {
+	switch key {
+	case "tab":
+		b.byRepo = !b.byRepo
+		b.group = ""
+		b.open(viewGroups)
+	case "enter", "right":
+		groups := b.groups()
+		if b.cursor < len(groups) {
+			b.group = groups[b.cursor].name
+			b.open(viewGrants)
+		}
+	case "esc":
+		b.query, b.cursor, b.offset = "", 0, 0
+	}
+}
+
+func (b *browser) handleGrantsKey(key string) {
+	switch key {
+	case "space":
+		visible := b.visibleGrants()
+		if b.cursor < len(visible) {
+			visible[b.cursor].selected = !visible[b.cursor].selected
+			b.moveCursor(1)
+		}
+	case "a":
+		b.selectAll()
+	case "p":
+		if len(b.targets()) > 0 {
+			b.mode = modePermission
+		}
+	case "x":
+		b.stage(actionRevoke, "")
+	case "u":
+		b.unstage()
+	case "esc", "left", "backspace":
+		b.clearSelection()
+		b.open(viewGroups)
+	}
+}
+
+func (b *browser) handlePendingKey(key string) {
+	switch key {
+	case "space":
+		visible := b.visibleGrants()
+		if b.cursor < len(visible) {
+			visible[b.cursor].selected = !visible[b.cursor].selected
+			b.moveCursor(1)
+		}
+	case "a":
+		b.selectAll()
+	case "u":
+		b.unstage()
+		b.moveCursor(0)
+	case "A":
+		if b.pendingCount() > 0 {
+			b.mode = modeConfirmApply
+		}
+	case "esc", "left", "backspace":
+		b.clearSelection()
+		b.open(b.previous)
+	}
+}
+
+// selectAll selects every grant in view, or clears the selection when they
+// are all already selected.
+func (b *browser) selectAll() {
+	visible := b.visibleGrants()
+	all := true
+	for _, gr := range visible {
+		all = all && gr.selected
+	}
+	for _, gr := range visible {
+		gr.selected = !all
+	}
+}
+
+func (b *browser) clearSelection() {
+	for _, gr := range b.grants {
+		gr.selected = false
+	}
+}
+
+// stage marks the target grants to be revoked or changed to level. Grants
+// coming only from a team or the organization are skipped, as removing the
+// repository collaborator would not change them.
+func (b *browser) stage(action string, level string) {
+	staged, skipped := 0, 0
+	for _, gr := range b.targets() {
+		gr.selected = false
+		if !gr.direct() {
+			skipped++
+			continue
+		}
+		gr.errMessage = ""
+		if action == actionChange && strings.EqualFold(level, gr.AccessLevel) {
+			gr.action, gr.newAccessLevel = "", ""
+			continue
+		}
+		gr.action, gr.newAccessLevel = action, level
+		staged++
+	}
+	b.message = fmt.Sprintf("%d changes pending.", b.pendingCount())
+	if skipped > 0 {
+		b.message = fmt.Sprintf("Skipped %d grants from a team or the organization, change them there instead. %s", skipped, b.message)
+	}
+}
+
+func (b *browser) unstage() {
+	for _, gr := range b.targets() {
+		gr.selected = false
+		gr.action, gr.newAccessLevel, gr.errMessage = "", "", ""
+	}
+	b.message = fmt.Sprintf("%d changes pending.", b.pendingCount())
+}
+
+// apply makes the pending changes through the same calls as the add and
+// remove commands, keeping those that fail pending with their error. The
+// access of each changed grant is read again afterwards, as a team or the
+// organization may still grant the user access to the repository.
+func (b *browser) apply() {
+	var pending []*grant
+	for _, gr := range b.grants {
+		if gr.action != "" {
+			pending = append(pending, gr)
+		}
+	}
+
+	applied, remaining, unknown := 0, 0, 0
+	revoked := make(map[*grant]bool)
+	for i, gr := range pending {
+		b.message = fmt.Sprintf("Applying %d of %d changes...", i+1, len(pending))
+		b.draw()
+		r := result{
+			repository:     gr.RepositoryName,
+			username:       gr.Username,
+			accessLevel:    gr.AccessLevel,
+			action:         gr.action,
+			newAccessLevel: gr.newAccessLevel,
+			status:         "applied",
+		}
+		if err := b.applyChange(gr); err != nil {
+			r.status, r.errMessage = "failed", err.Error()
+			gr.errMessage = err.Error()
+		} else {
+			applied++
+			action := gr.action
+			gr.action, gr.newAccessLevel, gr.errMessage = "", "", ""
+			access, err := b.refresh(gr)
+			switch {
+			case err != nil:
+				// keep showing the grant as it was, as the change is not confirmed
+				unknown++
+				r.status, r.errMessage = "unverified", err.Error()
+				gr.unverified = err.Error()
+			case access == "":
+				revoked[gr] = true
+			case action == actionRevoke || !strings.EqualFold(access, r.newAccessLevel):
+				remaining++
+			}
+		}
+		b.results = append(b.results, r)
+	}
+
+	var kept []*grant
+	for _, gr := range b.grants {
+		if !revoked[gr] {
+			kept = append(kept, gr)
+		}
+	}
+	b.grants = kept
+	b.moveCursor(0)
+	b.message = fmt.Sprintf("Applied %d of %d changes.", applied, len(pending))
+	if applied < len(pending) {
+		b.message += " Failed changes are still pending."
+	}
+	if remaining > 0 {
+		b.message += fmt.Sprintf(" %d changed grants still give access through a team or the organization.", remaining)
+	}
+	if unknown > 0 {
+		b.message += fmt.Sprintf(" The access of %d changed grants could not be read again, they are marked unverified.", unknown)
+	}
+}
+
+// refresh reads the access of an applied grant again, returning the
+// permission the user is left with, if any.
+func (b *browser) refresh(gr *grant) (string, error) {
+	repoInfo, err := b.g.GetRepoUserPermissions(b.owner, gr.RepositoryName, gr.Username)
+	if err != nil {
+		return "", err
+	}
+	edge, ok, err := b.g.UserCollaboratorEdge(b.owner, *repoInfo, gr.Username)
+	if err != nil || !ok {
+		return "", err
+	}
+	gr.unverified = ""
+	gr.AccessLevel = edge.Permission
+	gr.DirectAccess, gr.TeamAccess, gr.OrganizationAccess = utils.AccessSources(edge)
+	return edge.Permission, nil
+}
+
+func (b *browser) applyChange(gr *grant) error {
+	if gr.action == actionRevoke {
+		return b.g.RemoveRepoCollaborator(b.owner, gr.RepositoryName, gr.Username)
+	}
+	assignRepo, err := json.Marshal(utils.CreateRepoPermData(utils.RESTPermission(gr.newAccessLevel)))
+	if err != nil {
+		return err
+	}
+	return b.g.AddRepoCollaborator(b.owner, gr.RepositoryName, gr.Username, bytes.NewReader(assignRepo))
+}
+
+func (b *browser) size() (int, int) {
+	width, height, err := term.GetSize(int(b.out.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// bodyHeight is the number of list lines below the title and above the
+// status and help lines.
+func (b *browser) bodyHeight() int {
+	_, height := b.size()
+	if height < 4 {
+		return 1
+	}
+	return height - 3
+}
+
+func (b *browser) draw() {
+	width, _ := b.size()
+	bodyHeight := b.bodyHeight()
+
+	title, body := b.render()
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+bodyHeight {
+		b.offset = b.cursor - bodyHeight + 1
+	}
+
+	var screen strings.Builder
+	screen.WriteString("\033[H")
+	writeLine(&screen, "\033[1m", title, width)
+	for i := b.offset; i < b.offset+bodyHeight; i++ {
+		switch {
+		case i >= len(body):
+			writeLine(&screen, "", "", width)
+		case i == b.cursor:
+			writeLine(&screen, "\033[7m", body[i], width)
+		default:
+			writeLine(&screen, "", body[i], width)
+		}
+	}
+	writeLine(&screen, "", b.statusLine(), width)
+	screen.WriteString("\033[2m" + truncate(b.helpLine(), width) + "\033[0m\033[K")
+	fmt.Fprint(b.out, screen.String())
+}
+
+// writeLine writes a line of the screen in style, padded to the width so the
+// cursor highlight spans it, clearing whatever was drawn there before.
+func writeLine(screen *strings.Builder, style string, line string, width int) {
+	line = truncate(line, width)
+	if style != "" {
+		line = style + line + strings.Repeat(" ", width-len([]rune(line))) + "\033[0m"
+	}
+	screen.WriteString(line + "\033[K\r\n")
+}
+
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return line
+}
+
+func isText(key string) bool {
+	for _, r := range key {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return len(key) > 0
+}
+
+func (b *browser) render() (string, []string) {
+	var body []string
+	groupKind, itemKind := "users", "repositories"
+	if b.byRepo {
+		groupKind, itemKind = "repositories", "users"
+	}
+
+	switch b.view {
+	case viewGroups:
+		groups := b.groups()
+		nameWidth := 0
+		for _, entry := range groups {
+			nameWidth = max(nameWidth, len(entry.name))
+		}
+		for _, entry := range groups {
+			line := fmt.Sprintf("  %-*s  %4d grants", nameWidth, entry.name, entry.grants)
+			if entry.pending > 0 {
+				line += fmt.Sprintf("  %d pending", entry.pending)
+			}
+			body = append(body, line)
+		}
+		return fmt.Sprintf("%s: %d %s, %d grants, %d pending changes", b.owner, len(groups), groupKind, len(b.grants), b.pendingCount()), body
+
+	case viewGrants:
+		visible := b.visibleGrants()
+		nameWidth := 0
+		for _, gr := range visible {
+			nameWidth = max(nameWidth, len(b.itemName(gr)))
+		}
+		for _, gr := range visible {
+			body = append(body, fmt.Sprintf("%s %-*s  %-8s  %-30s  %s", checkbox(gr), nameWidth, b.itemName(gr), gr.AccessLevel, gr.source(), gr.pendingText()))
+		}
+		kind := "user"
+		if b.byRepo {
+			kind = "repository"
+		}
+		return fmt.Sprintf("%s > %s %s: %d %s", b.owner, kind, b.group, len(visible), itemKind), body
+
+	default:
+		visible := b.visibleGrants()
+		userWidth, repoWidth := 0, 0
+		for _, gr := range visible {
+			userWidth = max(userWidth, len(gr.Username))
+			repoWidth = max(repoWidth, len(gr.RepositoryName))
+		}
+		for _, gr := range visible {
+			body = append(body, fmt.Sprintf("%s %-*s  %-*s  %-8s  %s", checkbox(gr), userWidth, gr.Username, repoWidth, gr.RepositoryName, gr.AccessLevel, gr.pendingText()))
+		}
+		return fmt.Sprintf("%s > pending changes: %d", b.owner, b.pendingCount()), body
+	}
+}
+
+func checkbox(gr *grant) string {
+	if gr.selected {
+		return "[x]"
+	}
+	return "[ ]"
+}
+
+func (b *browser) statusLine() string {
+	switch b.mode {
+	case modeSearch:
+		return "/" + b.query
+	case modePermission:
+		return fmt.Sprintf("New permission for %d grants: [r]ead [t]riage [w]rite [m]aintain [a]dmin, any other key to cancel", len(b.targets()))
+	case modeConfirmApply:
+		return fmt.Sprintf("Apply %d changes to %s? [y/N]", b.pendingCount(), b.owner)
+	case modeConfirmQuit:
+		return fmt.Sprintf("Quit and discard %d pending changes? [y/N]", b.pendingCount())
+	}
+	if b.query != "" {
+		return fmt.Sprintf("Search: %s  %s", b.query, b.message)
+	}
+	return b.message
+}
+
+func (b *browser) helpLine() string {
+	switch b.view {
+	case viewGroups:
+		return "up/down move  enter open  tab users/repositories  / search  c pending changes  q quit"
+	case viewGrants:
+		return "space select  a select all  p permission  x revoke  u undo  / search  esc back  c pending changes  q quit"
+	default:
+		return "space select  a select all  u undo  A apply  / search  esc back  q quit"
+	}
+}
@@ -0,0 +1,349 @@
+package check
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token       string
+	hostname    string
+	policyFile  string
+	fileName    string
+	outputFile  string
+	remediate   bool
+	dryRun      bool
+	allowlist   string
+	resultsFile string
+	debug       bool
+}
+
+func NewCmdCheck() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	checkCmd := &cobra.Command{
+		Use:   "check [flags] <organization>",
+		Short: "Check repository collaborator access against a policy.",
+		Long:  "Check repository collaborator access, live or from a saved report, against the rules of a policy file, exiting non-zero when any rule is violated.",
+		Args:  cobra.MinimumNArgs(1),
+		// violations are reported as an error, which should not print usage
+		SilenceUsage: true,
+		RunE: func(checkCmd *cobra.Command, args []string) error {
+			var err error
+			var gqlClient api.GQLClient
+			var restClient api.RESTClient
+
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			if cmdFlags.dryRun && !cmdFlags.remediate {
+				return fmt.Errorf("--dry-run can only be used with --remediate")
+			}
+			if len(cmdFlags.fileName) > 0 && cmdFlags.remediate && !cmdFlags.dryRun {
+				return fmt.Errorf("--remediate can only be used with --from-file together with --dry-run, as a saved report may be out of date")
+			}
+
+			// a saved report is checked without calling the API
+			var g *utils.APIGetter
+			if len(cmdFlags.fileName) == 0 {
+				if cmdFlags.token != "" {
+					authToken = cmdFlags.token
+				} else {
+					t, _ := auth.TokenForHost(cmdFlags.hostname)
+					authToken = t
+				}
+
+				// debug logging is also written to stderr, so it is not redrawn over
+				progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
+				restClient, err = gh.RESTClient(&api.ClientOptions{
+					Headers: map[string]string{
+						"Accept": "application/vnd.github+json",
+					},
+					Host:      cmdFlags.hostname,
+					AuthToken: authToken,
+					Transport: progress.Transport(nil),
+				})
+
+				if err != nil {
+					zap.S().Errorf("Error arose retrieving rest client")
+					return err
+				}
+
+				gqlClient, err = gh.GQLClient(&api.ClientOptions{
+					Headers: map[string]string{
+						"Accept": "application/vnd.github.hawkgirl-preview+json",
+					},
+					Host:      cmdFlags.hostname,
+					AuthToken: authToken,
+					Transport: progress.Transport(nil),
+				})
+
+				if err != nil {
+					zap.S().Errorf("Error arose retrieving graphql client")
+					return err
+				}
+				g = utils.NewAPIGetter(gqlClient, restClient)
+				g.SetProgress(progress)
+			}
+
+			owner := args[0]
+
+			return runCmdCheck(owner, &cmdFlags, g, os.Stdout)
+		},
+	}
+
+	resultsFileDefault := fmt.Sprintf("PolicyRemediation-%s.csv", time.Now().Format("20060102150405"))
+
+	// Configure flags for command
+
+	checkCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	checkCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	checkCmd.Flags().StringVarP(&cmdFlags.policyFile, "policy", "p", "", "Path and Name of YAML policy file to check access against (required)")
+	checkCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV or JSON report to check instead of live access")
+	checkCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", "", "Name of file to write CSV list of violations to")
+	checkCmd.Flags().BoolVarP(&cmdFlags.remediate, "remediate", "", false, "Downgrade or remove grants that violate the policy")
+	checkCmd.Flags().BoolVarP(&cmdFlags.dryRun, "dry-run", "", false, "Report the remediations that would be made without making them")
+	checkCmd.Flags().StringVarP(&cmdFlags.allowlist, "allowlist", "a", "", "Path and Name of CSV file of RepositoryName and Username grants to never remediate")
+	checkCmd.Flags().StringVarP(&cmdFlags.resultsFile, "results-file", "r", resultsFileDefault, "Name of file to write CSV results of remediations to")
+	checkCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+	checkCmd.MarkFlagRequired("policy")
+
+	return checkCmd
+}
+
+func runCmdCheck(owner string, cmdFlags *cmdFlags, g *utils.APIGetter, checkWriter io.Writer) error {
+	zap.S().Debugf("Reading policy from %s", cmdFlags.policyFile)
+	policy, err := utils.LoadPolicy(cmdFlags.policyFile)
+	if err != nil {
+		return err
+	}
+
+	var rows []data.ReportRow
+	if len(cmdFlags.fileName) > 0 {
+		zap.S().Debugf("Opening up file %s", cmdFlags.fileName)
+		f, err := os.Open(cmdFlags.fileName)
+		if err != nil {
+			zap.S().Errorf("Error arose opening report file %s", cmdFlags.fileName)
+			return err
+		}
+		defer f.Close()
+		rows, err = utils.ReadReport(f)
+		if err != nil {
+			zap.S().Errorf("Error arose reading report file %s", cmdFlags.fileName)
+			return err
+		}
+	} else {
+		rows, err = g.GetReportRows(owner, utils.ReportOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	zap.S().Debugf("Evaluating %d rules against %d rows", len(policy.Rules), len(rows))
+	violations := utils.EvaluatePolicy(policy, rows)
+
+	if len(cmdFlags.outputFile) > 0 {
+		if err = writeViolationsFile(cmdFlags.outputFile, violations); err != nil {
+			zap.S().Error("Error raised in writing output", zap.Error(err))
+			return err
+		}
+	}
+
+	if len(violations) == 0 {
+		fmt.Fprintf(checkWriter, "No policy violations found for repository collaborators in %s.\n", owner)
+		return nil
+	}
+	tw := tabwriter.NewWriter(checkWriter, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tUSER\tREPOSITORY\tACCESS\tVIOLATION")
+	for _, v := range violations {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", v.Rule, v.Username, v.RepositoryName, v.AccessLevel, v.Message)
+	}
+	if err = tw.Flush(); err != nil {
+		return err
+	}
+
+	if cmdFlags.remediate {
+		return remediateViolations(owner, violations, cmdFlags, g)
+	}
+	return fmt.Errorf("found %d policy violations for repository collaborators in %s", len(violations), owner)
+}
+
+func writeViolationsFile(fileName string, violations []data.PolicyViolation) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	csvWriter := csv.NewWriter(f)
+	err = csvWriter.Write([]string{"Rule", "RepositoryName", "RepositoryID", "Visibility", "Username", "AccessLevel", "Message"})
+	if err != nil {
+		return err
+	}
+	for _, v := range violations {
+		err = csvWriter.Write([]string{v.Rule, v.RepositoryName, v.RepositoryID, v.Visibility, v.Username, v.AccessLevel, v.Message})
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+type remediation struct {
+	repository     string
+	username       string
+	accessLevel    string
+	rules          []string
+	action         string
+	newAccessLevel string
+}
+
+// planRemediations merges the violations of each grant into one action,
+// removal taking precedence over the lowest downgrade.
+func planRemediations(violations []data.PolicyViolation) []*remediation {
+	var planned []*remediation
+	byGrant := make(map[string]*remediation)
+	for _, v := range violations {
+		key := v.RepositoryName + "/" + v.Username
+		r, ok := byGrant[key]
+		if !ok || v.RepositoryName == "" {
+			r = &remediation{repository: v.RepositoryName, username: v.Username, accessLevel: v.AccessLevel}
+			byGrant[key] = r
+			planned = append(planned, r)
+		}
+		r.rules = append(r.rules, v.Rule)
+		switch {
+		case v.Remediation == utils.RemediationRemove || r.action == utils.RemediationRemove:
+			r.action, r.newAccessLevel = utils.RemediationRemove, ""
+		case v.Remediation == utils.RemediationDowngrade:
+			if r.action == "" || utils.PermissionRank(v.NewAccessLevel) < utils.PermissionRank(r.newAccessLevel) {
+				r.action, r.newAccessLevel = utils.RemediationDowngrade, v.NewAccessLevel
+			}
+		}
+	}
+	return planned
+}
+
+func remediateViolations(owner string, violations []data.PolicyViolation, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	var allowlist []data.ImportedRepoCollab
+	if len(cmdFlags.allowlist) > 0 {
+		var err error
+		allowlist, err = utils.LoadAllowlist(cmdFlags.allowlist)
+		if err != nil {
+			zap.S().Errorf("Error arose reading allowlist %s", cmdFlags.allowlist)
+			return err
+		}
+	}
+
+	resultsWriter, err := os.Create(cmdFlags.resultsFile)
+	if err != nil {
+		return err
+	}
+	defer resultsWriter.Close()
+	csvWriter := csv.NewWriter(resultsWriter)
+	err = csvWriter.Write([]string{"RepositoryName", "Username", "Rules", "AccessLevel", "Action", "NewAccessLevel", "Status", "Error"})
+	if err != nil {
+		zap.S().Error("Error raised in writing output", zap.Error(err))
+	}
+
+	unresolved := 0
+	for _, r := range planRemediations(violations) {
+		status, errMessage := "", ""
+		switch {
+		case r.action == "":
+			status = "manual"
+			unresolved++
+		case utils.IsAllowlisted(allowlist, r.repository, r.username):
+			zap.S().Debugf("Skipping allowlisted grant for %s on repo %s", r.username, r.repository)
+			status = "allowlisted"
+		case cmdFlags.dryRun:
+			status = "dry-run"
+			unresolved++
+		default:
+			var err error
+			status, errMessage, err = applyRemediation(owner, r, g)
+			if err != nil {
+				zap.S().Errorf("Error arose remediating access for user %s and repo %s", r.username, r.repository)
+				status, errMessage = "failed", err.Error()
+			}
+			if status == "failed" || status == "manual" {
+				unresolved++
+			}
+		}
+		err = csvWriter.Write([]string{r.repository, r.username, strings.Join(r.rules, ";"), r.accessLevel, r.action, r.newAccessLevel, status, errMessage})
+		if err != nil {
+			zap.S().Error("Error raised in writing output", zap.Error(err))
+		}
+	}
+	csvWriter.Flush()
+	if err = csvWriter.Error(); err != nil {
+		return err
+	}
+
+	if unresolved > 0 {
+		return fmt.Errorf("%d policy violations for repository collaborators in %s were not remediated, see %s", unresolved, owner, cmdFlags.resultsFile)
+	}
+	fmt.Printf("Successfully remediated policy violations for repository collaborators in %s. Results written to %s.", owner, cmdFlags.resultsFile)
+	return nil
+}
+
+// applyRemediation re-reads the live access of the grant before changing it,
+// as it may have changed since the violations were found, returning the
+// status of the remediation and why it was not made.
+func applyRemediation(owner string, r *remediation, g *utils.APIGetter) (string, string, error) {
+	repoInfo, err := g.GetRepoUserPermissions(owner, r.repository, r.username)
+	if err != nil {
+		return "", "", err
+	}
+	edge, ok, err := g.UserCollaboratorEdge(owner, *repoInfo, r.username)
+	if err != nil {
+		return "", "", err
+	}
+	if !ok {
+		zap.S().Debugf("Skipping %s on repo %s, no longer a collaborator", r.username, r.repository)
+		return "skipped", "no longer a collaborator", nil
+	}
+	direct, _, _ := utils.AccessSources(edge)
+	if direct == "" {
+		return "manual", "access is granted by " + utils.DescribeAccessSources(edge), nil
+	}
+
+	if r.action == utils.RemediationRemove {
+		zap.S().Debugf("Removing Repository Assignment for %s from repo %s", r.username, r.repository)
+		return "remediated", "", g.RemoveRepoCollaborator(owner, r.repository, r.username)
+	}
+	// a PUT at a higher level would raise the access instead
+	if utils.PermissionRank(direct) <= utils.PermissionRank(r.newAccessLevel) {
+		zap.S().Debugf("Skipping %s on repo %s, direct access %s is not above %s", r.username, r.repository, direct, r.newAccessLevel)
+		return "skipped", fmt.Sprintf("direct access is already %s", direct), nil
+	}
+	zap.S().Debugf("Downgrading %s on repo %s from %s to %s", r.username, r.repository, direct, r.newAccessLevel)
+	assignRepo, err := json.Marshal(utils.CreateRepoPermData(utils.RESTPermission(r.newAccessLevel)))
+	if err != nil {
+		return "", "", err
+	}
+	return "remediated", "", g.AddRepoCollaborator(owner, r.repository, r.username, bytes.NewReader(assignRepo))
+}
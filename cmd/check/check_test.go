@@ -0,0 +1,82 @@
+package check
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+)
+
+func TestPlanRemediations(t *testing.T) {
+	tests := []struct {
+		name       string
+		violations []data.PolicyViolation
+		want       []remediation
+	}{
+		{
+			name: "single removal",
+			violations: []data.PolicyViolation{
+				{Rule: "no-admin", RepositoryName: "api", Username: "alice", AccessLevel: "ADMIN", Remediation: utils.RemediationRemove},
+			},
+			want: []remediation{
+				{repository: "api", username: "alice", accessLevel: "ADMIN", rules: []string{"no-admin"}, action: utils.RemediationRemove},
+			},
+		},
+		{
+			name: "lowest downgrade wins",
+			violations: []data.PolicyViolation{
+				{Rule: "max-maintain", RepositoryName: "api", Username: "alice", AccessLevel: "ADMIN", Remediation: utils.RemediationDowngrade, NewAccessLevel: "MAINTAIN"},
+				{Rule: "max-read", RepositoryName: "api", Username: "alice", AccessLevel: "ADMIN", Remediation: utils.RemediationDowngrade, NewAccessLevel: "READ"},
+				{Rule: "max-write", RepositoryName: "api", Username: "alice", AccessLevel: "ADMIN", Remediation: utils.RemediationDowngrade, NewAccessLevel: "WRITE"},
+			},
+			want: []remediation{
+				{repository: "api", username: "alice", accessLevel: "ADMIN", rules: []string{"max-maintain", "max-read", "max-write"}, action: utils.RemediationDowngrade, newAccessLevel: "READ"},
+			},
+		},
+		{
+			name: "removal takes precedence over downgrade",
+			violations: []data.PolicyViolation{
+				{Rule: "max-write", RepositoryName: "api", Username: "alice", AccessLevel: "ADMIN", Remediation: utils.RemediationDowngrade, NewAccessLevel: "WRITE"},
+				{Rule: "no-public", RepositoryName: "api", Username: "alice", AccessLevel: "ADMIN", Remediation: utils.RemediationRemove},
+				{Rule: "max-read", RepositoryName: "api", Username: "alice", AccessLevel: "ADMIN", Remediation: utils.RemediationDowngrade, NewAccessLevel: "READ"},
+			},
+			want: []remediation{
+				{repository: "api", username: "alice", accessLevel: "ADMIN", rules: []string{"max-write", "no-public", "max-read"}, action: utils.RemediationRemove},
+			},
+		},
+		{
+			name: "grants are planned separately",
+			violations: []data.PolicyViolation{
+				{Rule: "no-admin", RepositoryName: "api", Username: "alice", AccessLevel: "ADMIN", Remediation: utils.RemediationRemove},
+				{Rule: "no-admin", RepositoryName: "docs", Username: "alice", AccessLevel: "ADMIN", Remediation: utils.RemediationRemove},
+			},
+			want: []remediation{
+				{repository: "api", username: "alice", accessLevel: "ADMIN", rules: []string{"no-admin"}, action: utils.RemediationRemove},
+				{repository: "docs", username: "alice", accessLevel: "ADMIN", rules: []string{"no-admin"}, action: utils.RemediationRemove},
+			},
+		},
+		{
+			name: "per-user violations are left for manual review",
+			violations: []data.PolicyViolation{
+				{Rule: "one-repo", Username: "alice"},
+				{Rule: "two-repos", Username: "alice"},
+			},
+			want: []remediation{
+				{username: "alice", rules: []string{"one-repo"}},
+				{username: "alice", rules: []string{"two-repos"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []remediation
+			for _, r := range planRemediations(tt.violations) {
+				got = append(got, *r)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planRemediations() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	format     string
+	outputFile string
+	debug      bool
+}
+
+func NewCmdDiff() *cobra.Command {
+	cmdFlags := cmdFlags{}
+
+	diffCmd := &cobra.Command{
+		Use:   "diff [flags] <old-report> <new-report>",
+		Short: "Show changes in access between two collaborator reports.",
+		Long:  "Show repository collaborators added, removed and with changed permissions between two CSV or JSON reports generated by list.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(diffCmd *cobra.Command, args []string) error {
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			if cmdFlags.format != "text" && cmdFlags.format != "csv" && cmdFlags.format != "json" {
+				return fmt.Errorf("unsupported format %q, must be one of text, csv or json", cmdFlags.format)
+			}
+
+			var diffWriter io.Writer = os.Stdout
+			if len(cmdFlags.outputFile) > 0 {
+				f, err := os.Create(cmdFlags.outputFile)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				diffWriter = f
+			}
+
+			return runCmdDiff(args[0], args[1], &cmdFlags, diffWriter)
+		},
+	}
+
+	// Configure flags for command
+
+	diffCmd.Flags().StringVarP(&cmdFlags.format, "format", "", "text", "Output format: text, csv or json")
+	diffCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", "", "Name of file to write the changes to (default stdout)")
+	diffCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return diffCmd
+}
+
+func runCmdDiff(oldReport string, newReport string, cmdFlags *cmdFlags, diffWriter io.Writer) error {
+	oldRows, err := readReportFile(oldReport)
+	if err != nil {
+		return err
+	}
+	newRows, err := readReportFile(newReport)
+	if err != nil {
+		return err
+	}
+
+	zap.S().Debugf("Comparing %d rows in %s with %d rows in %s", len(oldRows), oldReport, len(newRows), newReport)
+	changes := utils.DiffReports(oldRows, newRows)
+
+	switch cmdFlags.format {
+	case "csv":
+		return utils.WriteChangesCSV(diffWriter, changes)
+	case "json":
+		return utils.WriteChangesJSON(diffWriter, changes)
+	default:
+		return utils.WriteChangesText(diffWriter, changes)
+	}
+}
+
+func readReportFile(fileName string) ([]data.ReportRow, error) {
+	zap.S().Debugf("Opening up file %s", fileName)
+	f, err := os.Open(fileName)
+	if err != nil {
+		zap.S().Errorf("Error arose opening report file %s", fileName)
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := utils.ReadReport(f)
+	if err != nil {
+		zap.S().Errorf("Error arose reading report file %s", fileName)
+	}
+	return rows, err
+}
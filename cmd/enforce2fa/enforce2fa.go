@@ -0,0 +1,218 @@
+package enforce2fa
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token     string
+	hostname  string
+	grace     string
+	stateFile string
+	dryRun    bool
+	yes       bool
+	debug     bool
+}
+
+func NewCmdEnforce2FA() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	enforceCmd := &cobra.Command{
+		Use:   "enforce-2fa [flags] <organization>",
+		Short: "Remove repository collaborators without two-factor authentication.",
+		Long:  "Warn repository collaborators without two-factor authentication, and remove them from the organization's repositories once their grace period, tracked in a local state file, has passed.",
+		Args:  cobra.MinimumNArgs(1),
+		// removals that fail are reported as an error, which should not print usage
+		SilenceUsage: true,
+		RunE: func(enforceCmd *cobra.Command, args []string) error {
+			var err error
+			var gqlClient api.GQLClient
+			var restClient api.RESTClient
+
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			grace, err := utils.ParseDuration(cmdFlags.grace)
+			if err != nil {
+				return err
+			}
+
+			if cmdFlags.token != "" {
+				authToken = cmdFlags.token
+			} else {
+				t, _ := auth.TokenForHost(cmdFlags.hostname)
+				authToken = t
+			}
+
+			// debug logging is also written to stderr, so it is not redrawn over
+			progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
+			restClient, err = gh.RESTClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving rest client")
+				return err
+			}
+
+			gqlClient, err = gh.GQLClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github.hawkgirl-preview+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving graphql client")
+				return err
+			}
+
+			owner := args[0]
+			if !enforceCmd.Flags().Changed("state-file") {
+				cmdFlags.stateFile = fmt.Sprintf("TwoFactorState-%s.json", owner)
+			}
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetProgress(progress)
+			return runCmdEnforce2FA(owner, grace, &cmdFlags, g, os.Stdout)
+		},
+	}
+
+	// Configure flags for command
+
+	enforceCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	enforceCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	enforceCmd.Flags().StringVarP(&cmdFlags.grace, "grace", "g", "7d", "Time to allow repository collaborators to enable two-factor authentication, such as 7d or 2w")
+	enforceCmd.Flags().StringVarP(&cmdFlags.stateFile, "state-file", "s", "TwoFactorState-<organization>.json", "Name of file tracking when repository collaborators were first seen without two-factor authentication")
+	enforceCmd.Flags().BoolVarP(&cmdFlags.dryRun, "dry-run", "", false, "Report the repository collaborators that would be removed without removing them or updating the state file")
+	enforceCmd.Flags().BoolVarP(&cmdFlags.yes, "yes", "y", false, "Skip the confirmation prompt before removing repository collaborators")
+	enforceCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return enforceCmd
+}
+
+func runCmdEnforce2FA(owner string, grace time.Duration, cmdFlags *cmdFlags, g *utils.APIGetter, enforceWriter io.Writer) error {
+	zap.S().Debugf("Gathering repository collaborators without two-factor authentication in %s", owner)
+	disabled, err := g.GetOrgGuestsWithout2FA(owner)
+	if err != nil {
+		zap.S().Errorf("Error arose gathering two-factor authentication status, it requires organization owner access")
+		return err
+	}
+
+	state, err := utils.LoadTwoFactorState(cmdFlags.stateFile, owner)
+	if err != nil {
+		zap.S().Errorf("Error arose reading state file %s", cmdFlags.stateFile)
+		return err
+	}
+	now := time.Now()
+	deadlines := state.Update(disabled, now, grace)
+
+	var warnings, expired []utils.TwoFactorDeadline
+	for _, deadline := range deadlines {
+		if now.Before(deadline.Deadline) {
+			warnings = append(warnings, deadline)
+		} else {
+			expired = append(expired, deadline)
+		}
+	}
+
+	if len(deadlines) == 0 {
+		fmt.Fprintf(enforceWriter, "All repository collaborators in %s have two-factor authentication enabled.\n", owner)
+	}
+	if len(warnings) > 0 {
+		fmt.Fprintf(enforceWriter, "Repository collaborators to enable two-factor authentication before their deadline (%d):\n", len(warnings))
+		tw := tabwriter.NewWriter(enforceWriter, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  USER\tFIRST SEEN\tDEADLINE\tREMAINING")
+		for _, warning := range warnings {
+			remaining := warning.Deadline.Sub(now).Round(time.Hour)
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", warning.Username, warning.FirstSeen.Local().Format(time.DateTime), warning.Deadline.Local().Format(time.DateTime), remaining)
+		}
+		if err = tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	failed, err := removeExpired(owner, expired, state, cmdFlags, g, enforceWriter)
+	// a dry run does not start the grace period of newly seen users
+	if !cmdFlags.dryRun {
+		if saveErr := state.Save(cmdFlags.stateFile); saveErr != nil {
+			zap.S().Errorf("Error arose saving state file %s", cmdFlags.stateFile)
+			return saveErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d repository collaborators could not be removed", failed)
+	}
+	return nil
+}
+
+// removeExpired removes the repository collaborators whose grace period has
+// passed, returning how many could not be removed.
+func removeExpired(owner string, expired []utils.TwoFactorDeadline, state *utils.TwoFactorState, cmdFlags *cmdFlags, g *utils.APIGetter, enforceWriter io.Writer) (int, error) {
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	fmt.Fprintf(enforceWriter, "Repository collaborators past their deadline (%d):\n", len(expired))
+	for _, deadline := range expired {
+		fmt.Fprintf(enforceWriter, "  %s (deadline %s)\n", deadline.Username, deadline.Deadline.Local().Format(time.DateTime))
+	}
+	if cmdFlags.dryRun {
+		fmt.Fprintln(enforceWriter, "Dry run, no repository collaborators were removed.")
+		return 0, nil
+	}
+	if !cmdFlags.yes {
+		confirmed, err := utils.Confirm(os.Stdin, fmt.Sprintf("Remove %d repository collaborators from every repository in %s?", len(expired), owner))
+		if err != nil {
+			return 0, err
+		}
+		if !confirmed {
+			fmt.Fprintln(enforceWriter, "Aborted, no repository collaborators were removed.")
+			return 0, nil
+		}
+	}
+
+	failed := 0
+	g.Progress().Start("Removing repository collaborators", "users", len(expired))
+	for _, deadline := range expired {
+		g.Progress().Step()
+		zap.S().Debugf("Removing repository collaborator %s from %s", deadline.Username, owner)
+		err := g.RemoveOrgOutsideCollaborator(owner, deadline.Username)
+		if err != nil {
+			zap.S().Errorf("Error arose removing repository collaborator %s: %v", deadline.Username, err)
+			failed++
+			continue
+		}
+		state.Forget(deadline.Username)
+	}
+	g.Progress().Done()
+	fmt.Fprintf(enforceWriter, "Successfully removed %d repository collaborators without two-factor authentication from %s.\n", len(expired)-failed, owner)
+	return failed, nil
+}
@@ -0,0 +1,227 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	store      string
+	username   string
+	repo       string
+	permission string
+	at         string
+	format     string
+	debug      bool
+}
+
+func NewCmdHistory() *cobra.Command {
+	cmdFlags := cmdFlags{}
+
+	historyCmd := &cobra.Command{
+		Use:   "history [flags] <organization>",
+		Short: "Query repository collaborator access over time from a snapshot store.",
+		Long:  "Query repository collaborator access at a point in time, or the changes in access over time, from the snapshots saved by list --store.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(historyCmd *cobra.Command, args []string) error {
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			if cmdFlags.format != "text" && cmdFlags.format != "csv" && cmdFlags.format != "json" {
+				return fmt.Errorf("unsupported format %q, must be one of text, csv or json", cmdFlags.format)
+			}
+
+			owner := args[0]
+
+			return runCmdHistory(owner, &cmdFlags, os.Stdout)
+		},
+	}
+
+	// Configure flags for command
+
+	historyCmd.Flags().StringVarP(&cmdFlags.store, "store", "", "", "Directory of the snapshot store written by list --store (required)")
+	historyCmd.Flags().StringVarP(&cmdFlags.username, "username", "u", "", "Only include access of this repository collaborator")
+	historyCmd.Flags().StringVarP(&cmdFlags.repo, "repo", "r", "", "Only include access to this repository")
+	historyCmd.Flags().StringVarP(&cmdFlags.permission, "permission", "p", "", "Only include changes granting this access level, such as ADMIN")
+	historyCmd.Flags().StringVarP(&cmdFlags.at, "at", "", "", "Show access as of a date (YYYY-MM-DD) or time (RFC 3339) instead of changes")
+	historyCmd.Flags().StringVarP(&cmdFlags.format, "format", "", "text", "Output format: text, csv or json")
+	historyCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+	historyCmd.MarkFlagRequired("store")
+	historyCmd.MarkFlagsMutuallyExclusive("at", "permission")
+
+	return historyCmd
+}
+
+func runCmdHistory(owner string, cmdFlags *cmdFlags, historyWriter io.Writer) error {
+	store, err := utils.ReadSnapshotStore(cmdFlags.store)
+	if err != nil {
+		zap.S().Errorf("Error arose opening snapshot store %s", cmdFlags.store)
+		return err
+	}
+	defer store.Close()
+
+	snapshots, err := store.Snapshots(owner)
+	if err != nil {
+		zap.S().Errorf("Error arose reading snapshots for %s", owner)
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no snapshots of %s found in %s, run list --store first", owner, cmdFlags.store)
+	}
+	zap.S().Debugf("Read %d snapshots of %s", len(snapshots), owner)
+
+	if len(cmdFlags.at) > 0 {
+		at, err := parseDate(cmdFlags.at)
+		if err != nil {
+			return err
+		}
+		snapshot, ok := utils.SnapshotAt(snapshots, at)
+		if !ok {
+			return fmt.Errorf("no snapshots of %s were taken before %s, the first is from %s", owner, cmdFlags.at, snapshots[0].TakenAt.Local().Format(time.DateTime))
+		}
+		var rows []data.ReportRow
+		for _, row := range snapshot.Rows {
+			if matchesUser(cmdFlags, row.Username) && matchesRepo(cmdFlags, row.RepositoryName) {
+				rows = append(rows, row)
+			}
+		}
+		return writeAccess(historyWriter, snapshot.TakenAt, rows, cmdFlags.format)
+	}
+
+	var events []data.HistoryEvent
+	for _, event := range utils.SnapshotHistory(snapshots) {
+		if !matchesUser(cmdFlags, event.Username) || !matchesRepo(cmdFlags, event.RepositoryName, event.OldRepositoryName) {
+			continue
+		}
+		if len(cmdFlags.permission) == 0 || grantsPermission(event, cmdFlags.permission) {
+			events = append(events, event)
+		}
+	}
+	return writeEvents(historyWriter, events, cmdFlags.format)
+}
+
+// grantsPermission reports whether an event grants the access level. A
+// renamed repository keeps its access, so it grants nothing.
+func grantsPermission(event data.HistoryEvent, permission string) bool {
+	switch event.Change {
+	case utils.ChangeAdded, utils.ChangeChanged:
+		return strings.EqualFold(permission, event.NewAccessLevel)
+	}
+	return false
+}
+
+func matchesUser(cmdFlags *cmdFlags, username string) bool {
+	return len(cmdFlags.username) == 0 || strings.EqualFold(cmdFlags.username, username)
+}
+
+func matchesRepo(cmdFlags *cmdFlags, names ...string) bool {
+	if len(cmdFlags.repo) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if strings.EqualFold(cmdFlags.repo, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDate accepts an RFC 3339 time or a date, which is treated as the end
+// of that day in local time.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+func writeAccess(w io.Writer, takenAt time.Time, rows []data.ReportRow, format string) error {
+	switch format {
+	case "csv":
+		return utils.WriteReportCSV(w, append(append([]string{}, utils.BaseReportColumns...), "Team", "TeamRole"), rows)
+	case "json":
+		return utils.WriteReportJSON(w, rows)
+	}
+	fmt.Fprintf(w, "Access as of snapshot taken %s:\n", takenAt.Local().Format(time.DateTime))
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		target := row.RepositoryName
+		if row.Team != "" {
+			target = "team " + row.Team
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", row.Username, target, utils.GrantLevel(row))
+	}
+	return tw.Flush()
+}
+
+func writeEvents(w io.Writer, events []data.HistoryEvent, format string) error {
+	switch format {
+	case "csv":
+		csvWriter := csv.NewWriter(w)
+		err := csvWriter.Write([]string{"Date", "Change", "RepositoryID", "RepositoryName", "OldRepositoryName", "Username", "Team", "OldAccessLevel", "NewAccessLevel"})
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			err = csvWriter.Write([]string{
+				event.Date.Format(time.RFC3339),
+				event.Change,
+				event.RepositoryID,
+				event.RepositoryName,
+				event.OldRepositoryName,
+				event.Username,
+				event.Team,
+				event.OldAccessLevel,
+				event.NewAccessLevel,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	case "json":
+		if events == nil {
+			events = []data.HistoryEvent{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(events)
+	}
+	if len(events) == 0 {
+		_, err := fmt.Fprintln(w, "No matching changes in repository collaborator access.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, event := range events {
+		access := event.NewAccessLevel
+		switch event.Change {
+		case utils.ChangeRemoved:
+			access = event.OldAccessLevel
+		case utils.ChangeChanged:
+			access = event.OldAccessLevel + " -> " + event.NewAccessLevel
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", event.Date.Local().Format(time.DateTime), event.Change, event.Username, utils.ChangeTarget(event.ReportChange), access)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,195 @@
+package inactive
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token    string
+	hostname string
+	since    string
+	listFile string
+	debug    bool
+}
+
+func NewCmdInactive() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	inactiveCmd := &cobra.Command{
+		Use:   "inactive [flags] <organization>",
+		Short: "Generate a report of repository collaborators without recent activity.",
+		Long:  "Generate a report, usable as input to remove, of repository collaborators who have not contributed to the repositories they can access.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(inactiveCmd *cobra.Command, args []string) error {
+			var err error
+			var gqlClient api.GQLClient
+			var restClient api.RESTClient
+
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			if cmdFlags.token != "" {
+				authToken = cmdFlags.token
+			} else {
+				t, _ := auth.TokenForHost(cmdFlags.hostname)
+				authToken = t
+			}
+
+			// debug logging is also written to stderr, so it is not redrawn over
+			progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
+			restClient, err = gh.RESTClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving rest client")
+				return err
+			}
+
+			gqlClient, err = gh.GQLClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github.hawkgirl-preview+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving graphql client")
+				return err
+			}
+
+			owner := args[0]
+
+			since, err := utils.ParseDuration(cmdFlags.since)
+			if err != nil {
+				return err
+			}
+			if since > 365*24*time.Hour {
+				return fmt.Errorf("--since can be at most one year, got %s", cmdFlags.since)
+			}
+
+			reportWriter, err := os.Create(cmdFlags.listFile)
+
+			if err != nil {
+				return err
+			}
+			defer reportWriter.Close()
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetProgress(progress)
+			return runCmdInactive(owner, time.Now().Add(-since), g, reportWriter)
+		},
+	}
+
+	reportFileDefault := fmt.Sprintf("InactiveCollaboratorsReport-%s.csv", time.Now().Format("20060102150405"))
+
+	// Configure flags for command
+
+	inactiveCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	inactiveCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	inactiveCmd.Flags().StringVarP(&cmdFlags.since, "since", "s", "90d", "Period without activity, such as 90d or 12w (at most one year)")
+	inactiveCmd.Flags().StringVarP(&cmdFlags.listFile, "output-file", "o", reportFileDefault, "Name of file to write CSV list to")
+	inactiveCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return inactiveCmd
+}
+
+func runCmdInactive(owner string, since time.Time, g *utils.APIGetter, reportWriter io.Writer) error {
+	csvWriter := csv.NewWriter(reportWriter)
+
+	err := csvWriter.Write([]string{
+		"RepositoryName",
+		"Username",
+		"AccessLevel",
+	})
+
+	if err != nil {
+		zap.S().Error("Error raised in writing output", zap.Error(err))
+	}
+
+	zap.S().Debugf("Gathering repository collaborators for %s", owner)
+	repoCollaborators, err := g.GetOrgGuests(owner)
+	if err != nil {
+		return err
+	}
+
+	// finish the status line if gathering activity fails part way
+	defer g.Progress().Done()
+	g.Progress().Start("Gathering activity", "users", len(repoCollaborators))
+	for _, repoCollab := range repoCollaborators {
+		g.Progress().Step()
+		zap.S().Debugf("Gathering activity for username %s since %s", repoCollab.Login, since.Format(time.DateOnly))
+		activeRepos, complete, err := g.GetUserActiveRepos(owner, repoCollab.Login, since)
+		if err != nil {
+			zap.S().Errorf("Error arose gathering activity for user %s, skipping", repoCollab.Login)
+			continue
+		}
+
+		// a user whose access cannot be read would be missing from the report
+		allRepoPerms, err := g.GetUserRepoPermissions(owner, repoCollab.Login)
+		if err != nil {
+			zap.S().Errorf("Error arose gathering repositories for user %s", repoCollab.Login)
+			return err
+		}
+		for _, repo := range allRepoPerms {
+			edge, ok, err := g.UserCollaboratorEdge(owner, repo, repoCollab.Login)
+			if err != nil {
+				zap.S().Errorf("Error arose gathering collaborators of repo %s", repo.Name)
+				return err
+			}
+			if !ok || !utils.IsDirectGrant(edge) || activeRepos[repo.Name] {
+				continue
+			}
+			if !complete {
+				zap.S().Debugf("Checking activity of %s in repo %s", repoCollab.Login, repo.Name)
+				active, err := g.IsUserActiveInRepo(owner, repo.Name, repoCollab.Login, since)
+				if err != nil {
+					zap.S().Errorf("Error arose checking activity of user %s in repo %s, skipping", repoCollab.Login, repo.Name)
+					continue
+				}
+				if active {
+					continue
+				}
+			}
+			err = csvWriter.Write([]string{
+				repo.Name,
+				repoCollab.Login,
+				edge.Permission,
+			})
+			if err != nil {
+				zap.S().Error("Error raised in writing output", zap.Error(err))
+			}
+		}
+	}
+
+	g.Progress().Done()
+	csvWriter.Flush()
+	fmt.Printf("Successfully listed inactive repository collaborators for repositories in %s", owner)
+
+	return csvWriter.Error()
+}
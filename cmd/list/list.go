@@ -1,13 +1,13 @@
 package list
 
 import (
+	"bytes"
 	"encoding/csv"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cli/go-gh"
@@ -21,11 +21,32 @@ import (
 )
 
 type cmdFlags struct {
-	token    string
-	hostname string
-	listFile string
-	username string
-	debug    bool
+	token     string
+	hostname  string
+	listFile  string
+	usernames []string
+	usersFile string
+	explain   bool
+	teams     bool
+	format    string
+	layout    string
+	store     string
+	filter    utils.RepoFilter
+	columns   []string
+	force     bool
+	append    bool
+	no2FA     bool
+	debug     bool
+}
+
+// formatExtensions maps each supported report format to the extension of its
+// default output file.
+var formatExtensions = map[string]string{
+	"csv":      ".csv",
+	"json":     ".json",
+	"markdown": ".md",
+	"html":     ".html",
+	"xlsx":     ".xlsx",
 }
 
 func NewCmdList() *cobra.Command {
@@ -56,12 +77,16 @@ func NewCmdList() *cobra.Command {
 				authToken = t
 			}
 
+			// debug logging is also written to stderr, so it is not redrawn over
+			progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
 			restClient, err = gh.RESTClient(&api.ClientOptions{
 				Headers: map[string]string{
 					"Accept": "application/vnd.github+json",
 				},
 				Host:      cmdFlags.hostname,
 				AuthToken: authToken,
+				Transport: progress.Transport(nil),
 			})
 
 			if err != nil {
@@ -75,6 +100,7 @@ func NewCmdList() *cobra.Command {
 				},
 				Host:      cmdFlags.hostname,
 				AuthToken: authToken,
+				Transport: progress.Transport(nil),
 			})
 
 			if err != nil {
@@ -84,17 +110,78 @@ func NewCmdList() *cobra.Command {
 
 			owner := args[0]
 
-			if _, err := os.Stat(cmdFlags.listFile); errors.Is(err, os.ErrExist) {
+			if err = cmdFlags.filter.Compile(); err != nil {
+				return err
+			}
+			if cmdFlags.columns, err = utils.ParseMetadataColumns(cmdFlags.columns); err != nil {
 				return err
 			}
 
-			reportWriter, err := os.OpenFile(cmdFlags.listFile, os.O_WRONLY|os.O_CREATE, 0644)
+			extension, ok := formatExtensions[cmdFlags.format]
+			if !ok {
+				return fmt.Errorf("unsupported format %q, must be one of csv, json, markdown, html or xlsx", cmdFlags.format)
+			}
+			switch cmdFlags.layout {
+			case "long":
+			case "matrix":
+				if cmdFlags.format != "csv" && cmdFlags.format != "markdown" && cmdFlags.format != "html" {
+					return fmt.Errorf("the matrix layout is only supported by the csv, markdown and html formats")
+				}
+			default:
+				return fmt.Errorf("unsupported layout %q, must be one of long or matrix", cmdFlags.layout)
+			}
+			if !listCmd.Flags().Changed("output-file") {
+				cmdFlags.listFile = strings.TrimSuffix(cmdFlags.listFile, ".csv") + extension
+			}
+
+			if cmdFlags.append && (cmdFlags.layout != "long" || (cmdFlags.format != "csv" && cmdFlags.format != "json")) {
+				return fmt.Errorf("--append is only supported by the csv and json formats in the long layout")
+			}
+			if cmdFlags.append && cmdFlags.listFile == utils.StdioFileName {
+				return fmt.Errorf("--append needs an output file to merge with, not stdout")
+			}
+			if cmdFlags.listFile != utils.StdioFileName {
+				_, err = os.Stat(cmdFlags.listFile)
+				if err == nil && !cmdFlags.force && !cmdFlags.append {
+					return fmt.Errorf("output file %s already exists, use --force to overwrite it or --append to merge with it", cmdFlags.listFile)
+				}
+				if err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+			}
 
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetProgress(progress)
+			guests, err := resolveUsernames(owner, &cmdFlags, g)
 			if err != nil {
 				return err
 			}
 
-			return runCmdList(owner, &cmdFlags, utils.NewAPIGetter(gqlClient, restClient), reportWriter)
+			if cmdFlags.listFile == utils.StdioFileName {
+				if err = runCmdList(owner, &cmdFlags, guests, g, os.Stdout); err != nil {
+					return err
+				}
+				// the report is on stdout, so messages go to stderr
+				fmt.Fprintf(os.Stderr, "Successfully listed repository collaborator permissions for repositories in %s", owner)
+				return nil
+			}
+
+			// the report is only moved into place once it has been written in
+			// full, so a failed run leaves any existing file untouched
+			reportWriter, err := utils.CreateAtomic(cmdFlags.listFile)
+			if err != nil {
+				return err
+			}
+			defer reportWriter.Abort()
+
+			if err = runCmdList(owner, &cmdFlags, guests, g, reportWriter); err != nil {
+				return err
+			}
+			if err = reportWriter.Commit(); err != nil {
+				return err
+			}
+			fmt.Printf("Successfully listed repository collaborator permissions for repositories in %s", owner)
+			return nil
 		},
 	}
 
@@ -104,111 +191,217 @@ func NewCmdList() *cobra.Command {
 
 	listCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
 	listCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
-	listCmd.Flags().StringVarP(&cmdFlags.listFile, "output-file", "o", reportFileDefault, "Name of file to write CSV list to")
-	listCmd.PersistentFlags().StringVarP(&cmdFlags.username, "username", "u", "", "Username of single repo collaborator to generate report for")
+	listCmd.Flags().StringVarP(&cmdFlags.listFile, "output-file", "o", reportFileDefault, "Name of file to write the report to, or - for stdout")
+	listCmd.Flags().BoolVarP(&cmdFlags.force, "force", "", false, "Overwrite the output file if it already exists")
+	listCmd.Flags().BoolVarP(&cmdFlags.append, "append", "", false, "Merge the report into the existing output file, updating rows already in it")
+	listCmd.Flags().StringVarP(&cmdFlags.format, "format", "", "csv", "Report format: csv, json, markdown, html or xlsx")
+	listCmd.Flags().StringVarP(&cmdFlags.layout, "layout", "", "long", "Report layout: long, with a row per grant, or matrix, with a row per user and a column per repository")
+	listCmd.PersistentFlags().StringArrayVarP(&cmdFlags.usernames, "username", "u", nil, "Username of a repo collaborator to generate report for (repeatable)")
+	listCmd.Flags().StringVarP(&cmdFlags.usersFile, "users-file", "", "", "Path and Name of file of usernames, one per line, to generate report for")
+	listCmd.Flags().BoolVarP(&cmdFlags.explain, "explain", "e", false, "Add columns explaining the source of each collaborator's access")
+	listCmd.Flags().StringSliceVarP(&cmdFlags.columns, "columns", "c", nil, "Add repository (isArchived, isFork, pushedAt, defaultBranch, topics) and user (name, company, email, createdAt, 2fa) columns, separated by commas")
+	listCmd.Flags().BoolVarP(&cmdFlags.no2FA, "2fa-disabled", "", false, "Only include repository collaborators without two-factor authentication (requires organization owner access)")
+	listCmd.Flags().BoolVarP(&cmdFlags.teams, "teams", "", false, "Add team memberships of repository collaborators to the report")
+	listCmd.Flags().StringVarP(&cmdFlags.filter.Repo, "repo", "r", "", "Only include repositories matching a glob, or a regular expression between slashes")
+	listCmd.Flags().StringVarP(&cmdFlags.filter.Visibility, "visibility", "", "", "Only include repositories with this visibility: public, private or internal")
+	listCmd.Flags().StringVarP(&cmdFlags.filter.MinPermission, "min-permission", "", "", "Only include grants of at least this access level, such as write")
+	listCmd.Flags().StringVarP(&cmdFlags.filter.Topic, "topic", "", "", "Only include repositories with this topic")
+	listCmd.Flags().StringVarP(&cmdFlags.filter.Archived, "archived", "", utils.ArchivedInclude, "Archived repositories to include: include, exclude or only")
+	listCmd.Flags().StringVarP(&cmdFlags.filter.Language, "language", "", "", "Only include repositories with this primary language")
+	listCmd.Flags().StringVarP(&cmdFlags.store, "store", "", "", "Directory of the snapshot store to save the report to, for use with history")
 	listCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
 
+	listCmd.MarkFlagsMutuallyExclusive("force", "append")
+
+	// snapshots must hold every grant for history to be accurate
+	for _, filter := range []string{"username", "users-file", "2fa-disabled", "repo", "visibility", "min-permission", "topic", "archived", "language"} {
+		listCmd.MarkFlagsMutuallyExclusive("store", filter)
+	}
+
 	return listCmd
 }
 
-func runCmdList(owner string, cmdFlags *cmdFlags, g *utils.APIGetter, reportWriter io.Writer) error {
-	var reposCursor *string
-
-	csvWriter := csv.NewWriter(reportWriter)
+func runCmdList(owner string, cmdFlags *cmdFlags, guests []data.RepoCollaborators, g *utils.APIGetter, reportWriter io.Writer) error {
+	var existingRows []data.ReportRow
+	if cmdFlags.append {
+		// read before gathering the report, so a mismatched file fails fast
+		var err error
+		existingRows, err = readExistingReport(cmdFlags.listFile, cmdFlags.format, reportColumns(cmdFlags))
+		if err != nil {
+			zap.S().Errorf("Error arose reading existing report %s", cmdFlags.listFile)
+			return err
+		}
+	}
 
-	err := csvWriter.Write([]string{
-		"RepositoryName",
-		"RepositoryID",
-		"Visibility",
-		"Username",
-		"AccessLevel",
+	storing := len(cmdFlags.store) > 0
+	snapshotRows, err := g.GetReportRows(owner, utils.ReportOptions{
+		Usernames: cmdFlags.usernames,
+		Explain:   cmdFlags.explain,
+		// snapshots always hold team memberships, so toggling --teams
+		// between runs does not show up as changes in history
+		Teams:             cmdFlags.teams || storing,
+		Filter:            cmdFlags.filter,
+		Columns:           cmdFlags.columns,
+		TwoFactorDisabled: cmdFlags.no2FA,
+		Guests:            guests,
 	})
+	if utils.IsIncompleteReport(err) && !storing {
+		// a partial report is still useful, as long as it is flagged
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	} else if err != nil {
+		// a partial snapshot would show the missing rows as removed
+		return err
+	}
+
+	rows := snapshotRows
+	if !cmdFlags.teams {
+		rows = nil
+		for _, row := range snapshotRows {
+			if row.Team == "" {
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	reportRows := rows
+	if cmdFlags.append {
+		reportRows = utils.MergeReports(existingRows, rows)
+	}
 
+	switch {
+	case cmdFlags.layout == "matrix":
+		err = writeMatrix(reportWriter, owner, cmdFlags.format, reportRows)
+	case cmdFlags.format == "json":
+		err = utils.WriteReportJSON(reportWriter, reportRows)
+	case cmdFlags.format == "markdown":
+		err = utils.WriteReportMarkdown(reportWriter, reportColumns(cmdFlags), rows)
+	case cmdFlags.format == "html":
+		err = utils.WriteReportHTML(reportWriter, owner, reportColumns(cmdFlags), rows)
+	case cmdFlags.format == "xlsx":
+		zap.S().Debugf("Gathering pending repository invitations for %s", owner)
+		invitations, invitationErr := g.GetOrgRepoInvitations(owner)
+		if invitationErr != nil {
+			zap.S().Error("Error raised in gathering repository invitations", zap.Error(invitationErr))
+		}
+		err = utils.WriteReportXLSX(reportWriter, owner, reportColumns(cmdFlags), rows, invitations)
+	default:
+		err = utils.WriteReportCSV(reportWriter, reportColumns(cmdFlags), reportRows)
+	}
 	if err != nil {
 		zap.S().Error("Error raised in writing output", zap.Error(err))
+		return err
 	}
 
-	zap.S().Debugf("Gathering repositories and access for %s", owner)
-	repoCollabList, err := g.GetOrgGuestCollaborators(owner)
-	if err != nil {
-		zap.S().Error("Error raised in gathering users", zap.Error(err))
+	if storing {
+		zap.S().Debugf("Saving snapshot of %d rows to store %s", len(snapshotRows), cmdFlags.store)
+		store, err := utils.OpenSnapshotStore(cmdFlags.store)
+		if err != nil {
+			zap.S().Errorf("Error arose opening snapshot store %s", cmdFlags.store)
+			return err
+		}
+		defer store.Close()
+		if err = store.SaveSnapshot(owner, time.Now(), snapshotRows); err != nil {
+			zap.S().Errorf("Error arose saving snapshot to store %s", cmdFlags.store)
+			return err
+		}
 	}
 
-	var repoCollaborators []data.RepoCollaborators
-	err = json.Unmarshal(repoCollabList, &repoCollaborators)
+	return nil
+}
+
+// readExistingReport reads the report being appended to, which may not exist
+// yet. A csv report must have the columns being written, as the values of any
+// other columns would be dropped from the merged rows.
+func readExistingReport(fileName string, format string, columns []string) ([]data.ReportRow, error) {
+	content, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+	zap.S().Debugf("Reading existing report %s to append to", fileName)
+	if format == "csv" && len(bytes.TrimSpace(content)) > 0 {
+		header, err := csv.NewReader(bytes.NewReader(content)).Read()
+		if err != nil {
+			return nil, err
+		}
+		if !sameColumns(header, columns) {
+			return nil, fmt.Errorf("existing report %s has columns %s, but this report has columns %s, use the same --explain, --columns and --teams flags to append to it", fileName, strings.Join(header, ","), strings.Join(columns, ","))
+		}
 	}
+	return utils.ReadReport(bytes.NewReader(content))
+}
 
-	if len(cmdFlags.username) > 0 {
-		zap.S().Debugf("Checking if username %s is in list of repository collaborators", cmdFlags.username)
-		for _, repoCollab := range repoCollaborators {
-			if cmdFlags.username == repoCollab.Login {
-
-				zap.S().Debugf("Gathering repositories for specified username %s", cmdFlags.username)
-				var allRepoPerms []data.RepoInfo
-				for {
-					repoUserPermissions, err := g.GetOrgRepositoryPermissions(owner, cmdFlags.username, reposCursor)
-					if err != nil {
-						zap.S().Error("Error raised in gathering repositories and user permissions", zap.Error(err))
-					}
-					allRepoPerms = append(allRepoPerms, repoUserPermissions.Organization.Repositories.Nodes...)
-					if !repoUserPermissions.Organization.Repositories.PageInfo.HasNextPage {
-						break
-					}
-					reposCursor = &repoUserPermissions.Organization.Repositories.PageInfo.EndCursor
-				}
-				for _, repo := range allRepoPerms {
-					if len(repo.Collaborators.Edges) > 0 {
-						err = csvWriter.Write([]string{
-							repo.Name,
-							strconv.Itoa(repo.DatabaseId),
-							repo.Visibility,
-							cmdFlags.username,
-							repo.Collaborators.Edges[0].Permission,
-						})
-						if err != nil {
-							zap.S().Error("Error raised in writing output", zap.Error(err))
-						}
-					}
-				}
-			}
+func sameColumns(header []string, columns []string) bool {
+	if len(header) != len(columns) {
+		return false
+	}
+	for i, name := range header {
+		if !strings.EqualFold(strings.TrimSpace(name), columns[i]) {
+			return false
 		}
+	}
+	return true
+}
 
-	} else {
-		for _, repoCollab := range repoCollaborators {
-			zap.S().Debugf("Gathering repositories for username %s", repoCollab.Login)
-			var allRepoPerms []data.RepoInfo
-			for {
-				repoUserPermissions, err := g.GetOrgRepositoryPermissions(owner, repoCollab.Login, reposCursor)
-				if err != nil {
-					zap.S().Error("Error raised in gathering repositories and user permissions", zap.Error(err))
-				}
-				allRepoPerms = append(allRepoPerms, repoUserPermissions.Organization.Repositories.Nodes...)
-				if !repoUserPermissions.Organization.Repositories.PageInfo.HasNextPage {
-					break
-				}
-				reposCursor = &repoUserPermissions.Organization.Repositories.PageInfo.EndCursor
-			}
-			for _, repo := range allRepoPerms {
-				if len(repo.Collaborators.Edges) > 0 {
-					err = csvWriter.Write([]string{
-						repo.Name,
-						strconv.Itoa(repo.DatabaseId),
-						repo.Visibility,
-						repoCollab.Login,
-						repo.Collaborators.Edges[0].Permission,
-					})
-					if err != nil {
-						zap.S().Error("Error raised in writing output", zap.Error(err))
-					}
-				}
-			}
+// resolveUsernames adds the usernames of --users-file to those given with
+// --username, warning about any that are not repository collaborators and
+// failing when none are. The repository collaborators gathered to check the
+// usernames are returned for the report to reuse.
+func resolveUsernames(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) ([]data.RepoCollaborators, error) {
+	if len(cmdFlags.usersFile) > 0 {
+		zap.S().Debugf("Reading usernames from %s", cmdFlags.usersFile)
+		fileUsernames, err := utils.ReadUsernames(cmdFlags.usersFile)
+		if err != nil {
+			zap.S().Errorf("Error arose reading usernames file %s", cmdFlags.usersFile)
+			return nil, err
+		}
+		if len(fileUsernames) == 0 {
+			return nil, fmt.Errorf("no usernames found in %s", cmdFlags.usersFile)
 		}
+		cmdFlags.usernames = append(cmdFlags.usernames, fileUsernames...)
+	}
+	if len(cmdFlags.usernames) == 0 {
+		return nil, nil
 	}
 
-	fmt.Printf("Successfully listed repository collaborator permissions for repositories in %s", owner)
-	csvWriter.Flush()
+	zap.S().Debugf("Checking if %d usernames are in list of repository collaborators", len(cmdFlags.usernames))
+	guests, err := g.GetOrgGuests(owner)
+	if err != nil {
+		return nil, err
+	}
+	missing := utils.MissingGuests(guests, cmdFlags.usernames)
+	if len(missing) == len(cmdFlags.usernames) {
+		return nil, fmt.Errorf("none of the usernames are repository collaborators in %s: %s", owner, strings.Join(missing, ", "))
+	}
+	for _, username := range missing {
+		fmt.Fprintf(os.Stderr, "Skipping %s, not a repository collaborator in %s\n", username, owner)
+	}
+	return guests, nil
+}
 
-	return nil
+func reportColumns(cmdFlags *cmdFlags) []string {
+	columns := append([]string{}, utils.BaseReportColumns...)
+	if cmdFlags.explain {
+		columns = append(columns, "DirectAccess", "TeamAccess", "OrganizationAccess")
+	}
+	columns = append(columns, cmdFlags.columns...)
+	if cmdFlags.teams {
+		columns = append(columns, "Team", "TeamRole")
+	}
+	return columns
+}
+
+func writeMatrix(w io.Writer, owner string, format string, rows []data.ReportRow) error {
+	matrix := utils.BuildAccessMatrix(owner, rows)
+	zap.S().Debugf("Writing matrix of %d users and %d repositories", len(matrix.Rows), len(matrix.Repositories))
+	switch format {
+	case "markdown":
+		return utils.WriteMatrixMarkdown(w, matrix)
+	case "html":
+		return utils.WriteMatrixHTML(w, matrix)
+	default:
+		return utils.WriteMatrixCSV(w, matrix)
+	}
 }
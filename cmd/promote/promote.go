@@ -0,0 +1,277 @@
+package promote
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token          string
+	hostname       string
+	teams          []string
+	mapPermissions bool
+	yes            bool
+	debug          bool
+}
+
+type directGrant struct {
+	repository string
+	permission string
+	edge       data.Edge
+}
+
+func NewCmdPromote() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	promoteCmd := &cobra.Command{
+		Use:   "promote [flags] <organization> <username>",
+		Short: "Convert a repository collaborator to an organization member.",
+		Long:  "Invite a repository collaborator to become an organization member, and remove their redundant direct repository grants once the invitation is accepted.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(promoteCmd *cobra.Command, args []string) error {
+			var err error
+			var gqlClient api.GQLClient
+			var restClient api.RESTClient
+
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			if cmdFlags.token != "" {
+				authToken = cmdFlags.token
+			} else {
+				t, _ := auth.TokenForHost(cmdFlags.hostname)
+				authToken = t
+			}
+
+			// debug logging is also written to stderr, so it is not redrawn over
+			progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
+			restClient, err = gh.RESTClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving rest client")
+				return err
+			}
+
+			gqlClient, err = gh.GQLClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github.hawkgirl-preview+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving graphql client")
+				return err
+			}
+
+			owner := args[0]
+			username := args[1]
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetProgress(progress)
+			return runCmdPromote(owner, username, &cmdFlags, g)
+		},
+	}
+
+	// Configure flags for command
+
+	promoteCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	promoteCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	promoteCmd.Flags().StringArrayVarP(&cmdFlags.teams, "team", "", nil, "Slug of a team to add the user to with the invitation (repeatable)")
+	promoteCmd.Flags().BoolVarP(&cmdFlags.mapPermissions, "map-permissions", "m", false, "Grant the teams the user's current direct repository permissions")
+	promoteCmd.Flags().BoolVarP(&cmdFlags.yes, "yes", "y", false, "Skip the confirmation prompts before granting teams access and removing direct grants")
+	promoteCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return promoteCmd
+}
+
+func runCmdPromote(owner string, username string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	zap.S().Debugf("Gathering repositories for username %s", username)
+	allRepoPerms, err := g.GetUserRepoPermissions(owner, username)
+	if err != nil {
+		zap.S().Errorf("Error arose gathering repositories for user %s", username)
+		return err
+	}
+	var grants []directGrant
+	for _, repo := range allRepoPerms {
+		edge, ok, err := g.UserCollaboratorEdge(owner, repo, username)
+		if err != nil {
+			zap.S().Errorf("Error arose gathering collaborators of repo %s", repo.Name)
+			return err
+		}
+		if ok && utils.IsDirectGrant(edge) {
+			grants = append(grants, directGrant{repository: repo.Name, permission: edge.Permission, edge: edge})
+		}
+	}
+
+	zap.S().Debugf("Checking organization membership of %s", username)
+	membership, err := g.GetOrgMembership(owner, username)
+	if err != nil {
+		zap.S().Errorf("Error arose checking organization membership for user %s", username)
+		return err
+	}
+
+	switch {
+	case membership == nil:
+		return inviteMember(owner, username, grants, cmdFlags, g)
+	case membership.State == "pending":
+		fmt.Printf("The organization invitation for %s to %s is still pending. Run promote again once it has been accepted.", username, owner)
+		return nil
+	default:
+		return removeRedundantGrants(owner, username, grants, cmdFlags, g)
+	}
+}
+
+// teamGrant is a direct repository permission of the user that is mapped to
+// one of the teams they are invited to.
+type teamGrant struct {
+	team       string
+	repository string
+	permission string
+}
+
+func inviteMember(owner string, username string, grants []directGrant, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	invitation := data.OrgInvitation{Role: "direct_member"}
+
+	user, err := g.GetUser(username)
+	if err != nil {
+		zap.S().Errorf("Error arose retrieving user %s", username)
+		return err
+	}
+	invitation.InviteeId = user.Id
+
+	var teamGrants []teamGrant
+	for _, slug := range cmdFlags.teams {
+		team, err := g.GetTeam(owner, slug)
+		if err != nil {
+			zap.S().Errorf("Error arose retrieving team %s", slug)
+			return err
+		}
+		invitation.TeamIds = append(invitation.TeamIds, team.Id)
+
+		if !cmdFlags.mapPermissions {
+			continue
+		}
+		teamPermissions, err := g.GetTeamRepoPermissions(owner, slug)
+		if err != nil {
+			zap.S().Errorf("Error arose retrieving repositories for team %s", slug)
+			return err
+		}
+		for _, grant := range grants {
+			if utils.PermissionRank(teamPermissions[grant.repository]) >= utils.PermissionRank(grant.permission) {
+				continue
+			}
+			teamGrants = append(teamGrants, teamGrant{team: slug, repository: grant.repository, permission: grant.permission})
+		}
+	}
+
+	if len(teamGrants) > 0 {
+		for _, grant := range teamGrants {
+			fmt.Fprintf(os.Stderr, "%s\t%s\t%s\n", grant.team, grant.repository, grant.permission)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: these grants widen access for every existing member of the teams, not only %s.\n", username)
+		if !cmdFlags.yes {
+			confirmed, err := utils.Confirm(os.Stdin, fmt.Sprintf("Invite %s and grant %d team repository permissions?", username, len(teamGrants)))
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Println("Aborted, no invitation was sent.")
+				return nil
+			}
+		}
+	}
+
+	zap.S().Debugf("Inviting %s to organization %s", username, owner)
+	err = g.CreateOrgInvitation(owner, invitation)
+	if err != nil {
+		zap.S().Errorf("Error arose inviting user %s to organization %s", username, owner)
+		return err
+	}
+
+	failed := 0
+	for _, grant := range teamGrants {
+		zap.S().Debugf("Granting team %s permission %s on repo %s", grant.team, grant.permission, grant.repository)
+		err = g.AddTeamRepoPermission(owner, grant.team, grant.repository, utils.RESTPermission(grant.permission))
+		if err != nil {
+			zap.S().Errorf("Error arose granting team %s permission on repo %s", grant.team, grant.repository)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("invited %s to %s, but failed to grant %d of %d team repository permissions", username, owner, failed, len(teamGrants))
+	}
+
+	fmt.Printf("Successfully invited %s to %s. Run promote again once the invitation has been accepted to remove redundant direct repository grants.", username, owner)
+	return nil
+}
+
+func removeRedundantGrants(owner string, username string, grants []directGrant, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	var redundant []directGrant
+	for _, grant := range grants {
+		if utils.IsRedundantDirectGrant(grant.edge) {
+			redundant = append(redundant, grant)
+		} else {
+			zap.S().Debugf("Keeping direct grant %s on repo %s, it is not covered by a team or organization grant", grant.permission, grant.repository)
+		}
+	}
+
+	if len(redundant) == 0 {
+		fmt.Printf("%s is a member of %s and has no redundant direct repository grants.", username, owner)
+		return nil
+	}
+
+	for _, grant := range redundant {
+		fmt.Fprintf(os.Stderr, "%s\t%s\n", grant.repository, grant.permission)
+	}
+	if !cmdFlags.yes {
+		confirmed, err := utils.Confirm(os.Stdin, fmt.Sprintf("Remove %d redundant direct grants for %s?", len(redundant), username))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted, no access was removed.")
+			return nil
+		}
+	}
+
+	failed := 0
+	for _, grant := range redundant {
+		zap.S().Debugf("Removing redundant direct grant for %s from repo %s", username, grant.repository)
+		err := g.RemoveRepoCollaborator(owner, grant.repository, username)
+		if err != nil {
+			zap.S().Errorf("Error arose removing permission for user %s and repo %s", username, grant.repository)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("removed %d redundant direct repository grants for %s in %s, but failed to remove %d", len(redundant)-failed, username, owner, failed)
+	}
+
+	fmt.Printf("Successfully removed %d redundant direct repository grants for %s in: %s.", len(redundant), username, owner)
+	return nil
+}
@@ -1,9 +1,13 @@
-package add
+package remove
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/cli/go-gh"
 	"github.com/cli/go-gh/pkg/api"
@@ -13,13 +17,18 @@ import (
 	"github.com/katiem0/gh-collaborators/internal/utils"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"golang.org/x/term"
 )
 
 type cmdFlags struct {
-	token    string
-	hostname string
-	fileName string
-	debug    bool
+	token       string
+	hostname    string
+	fileName    string
+	users       []string
+	resultsFile string
+	yes         bool
+	maxChanges  int
+	debug       bool
 }
 
 func NewCmdRemove() *cobra.Command {
@@ -31,6 +40,8 @@ func NewCmdRemove() *cobra.Command {
 		Short: "Remove repo access for repository collaborators.",
 		Long:  "Remove repositories and permissions for repository collaborators.",
 		Args:  cobra.MinimumNArgs(1),
+		// refusing to remove access is reported as an error, which should not print usage
+		SilenceUsage: true,
 		RunE: func(removeCmd *cobra.Command, args []string) error {
 			var err error
 			var gqlClient api.GQLClient
@@ -50,12 +61,16 @@ func NewCmdRemove() *cobra.Command {
 				authToken = t
 			}
 
+			// debug logging is also written to stderr, so it is not redrawn over
+			progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
 			restClient, err = gh.RESTClient(&api.ClientOptions{
 				Headers: map[string]string{
 					"Accept": "application/vnd.github+json",
 				},
 				Host:      cmdFlags.hostname,
 				AuthToken: authToken,
+				Transport: progress.Transport(nil),
 			})
 
 			if err != nil {
@@ -69,6 +84,7 @@ func NewCmdRemove() *cobra.Command {
 				},
 				Host:      cmdFlags.hostname,
 				AuthToken: authToken,
+				Transport: progress.Transport(nil),
 			})
 
 			if err != nil {
@@ -77,18 +93,30 @@ func NewCmdRemove() *cobra.Command {
 			}
 
 			owner := args[0]
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetProgress(progress)
 
-			return runCmdRemove(owner, &cmdFlags, utils.NewAPIGetter(gqlClient, restClient))
+			if len(cmdFlags.users) > 0 {
+				return runCmdRemoveUsers(owner, &cmdFlags, g)
+			}
+			return runCmdRemove(owner, &cmdFlags, g)
 		},
 	}
 
+	resultsFileDefault := fmt.Sprintf("RepoCollaboratorsRemoval-%s.csv", time.Now().Format("20060102150405"))
+
 	// Configure flags for command
 
 	removeCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
 	removeCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
-	removeCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV file to remove access from (required)")
+	removeCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV or XLSX file to remove access from, or - for stdin")
+	removeCmd.Flags().StringArrayVarP(&cmdFlags.users, "user", "u", nil, "Username to remove from every repository and pending invitation (repeatable)")
+	removeCmd.Flags().StringVarP(&cmdFlags.resultsFile, "results-file", "r", resultsFileDefault, "Name of file to write CSV results of user removals to")
+	removeCmd.Flags().BoolVarP(&cmdFlags.yes, "yes", "y", false, "Skip the confirmation prompt, required when the file is read from stdin or there is no terminal")
+	removeCmd.Flags().IntVarP(&cmdFlags.maxChanges, "max-changes", "", 0, "Refuse to remove anything when more than this many grants, invitations and team memberships would be removed")
 	removeCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
-	removeCmd.MarkFlagRequired("from-file")
+	removeCmd.MarkFlagsOneRequired("from-file", "user")
+	removeCmd.MarkFlagsMutuallyExclusive("from-file", "user")
 
 	return removeCmd
 }
@@ -97,11 +125,25 @@ func runCmdRemove(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
 	var collabData [][]string
 	var importRepoCollabList []data.ImportedRepoCollab
 
-	if len(cmdFlags.fileName) > 0 {
-		f, err := os.Open(cmdFlags.fileName)
+	if utils.IsXLSXFile(cmdFlags.fileName) {
+		var err error
+		collabData, err = utils.ReadXLSXRecords(cmdFlags.fileName)
+		zap.S().Debugf("Reading in all rows from xlsx workbook")
+		if err != nil {
+			zap.S().Errorf("Error arose reading collaborators to remove from xlsx workbook")
+			return err
+		}
+		importRepoCollabList, err = g.DeleteRepoCollaboratorsList(collabData)
+		if err != nil {
+			zap.S().Errorf("Error arose reading the columns of %s", cmdFlags.fileName)
+			return err
+		}
+	} else if len(cmdFlags.fileName) > 0 {
+		f, err := utils.OpenInput(cmdFlags.fileName)
 		zap.S().Debugf("Opening up file %s", cmdFlags.fileName)
 		if err != nil {
 			zap.S().Errorf("Error arose opening repository collaborators csv file")
+			return err
 		}
 		// remember to close the file at the end of the program
 		defer f.Close()
@@ -111,21 +153,316 @@ func runCmdRemove(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
 		zap.S().Debugf("Reading in all lines from csv file")
 		if err != nil {
 			zap.S().Errorf("Error arose reading collaborators to remove from csv file")
+			return err
+		}
+		importRepoCollabList, err = g.DeleteRepoCollaboratorsList(collabData)
+		if err != nil {
+			zap.S().Errorf("Error arose reading the columns of %s", cmdFlags.fileName)
+			return err
 		}
-		importRepoCollabList = g.DeleteRepoCollaboratorsList(collabData)
 	} else {
 		zap.S().Errorf("Error arose identifying users to add")
 	}
+
+	grants, memberships := 0, 0
+	users := make(map[string]bool)
+	repos := make(map[string]bool)
+	for _, importRepoCollab := range importRepoCollabList {
+		if len(importRepoCollab.Team) > 0 {
+			memberships++
+		}
+		if len(importRepoCollab.RepositoryName) > 0 {
+			grants++
+			repos[strings.ToLower(importRepoCollab.RepositoryName)] = true
+		}
+		users[strings.ToLower(importRepoCollab.Username)] = true
+	}
+	if grants+memberships == 0 {
+		fmt.Printf("No repository access to remove found in %s.", cmdFlags.fileName)
+		return nil
+	}
+	if err := checkMaxChanges(cmdFlags, grants+memberships); err != nil {
+		return err
+	}
+	question := fmt.Sprintf("Remove %d grants on %d repositories and %d team memberships for %d users in %s?", grants, len(repos), memberships, len(users), owner)
+	confirmed, err := confirmRemoval(cmdFlags, question)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		fmt.Println("Aborted, no access was removed.")
+		return nil
+	}
+
 	zap.S().Debugf("Determining users to remove")
+	var removed []data.ImportedRepoCollab
+	failed := 0
+	g.Progress().Start("Removing access", "rows", len(importRepoCollabList))
 	for _, importRepoCollab := range importRepoCollabList {
+		g.Progress().Step()
+		if len(importRepoCollab.Team) > 0 {
+			zap.S().Debugf("Removing user %s from team %s", importRepoCollab.Username, importRepoCollab.Team)
+			err := g.RemoveTeamMembership(owner, importRepoCollab.Team, importRepoCollab.Username)
+			if err != nil {
+				zap.S().Errorf("Error arose removing user %s from team %s", importRepoCollab.Username, importRepoCollab.Team)
+				failed++
+			}
+		}
+		if len(importRepoCollab.RepositoryName) == 0 {
+			continue
+		}
 		zap.S().Debugf("Removing Repository Assignment for %s", importRepoCollab.Username)
 
 		err := g.RemoveRepoCollaborator(owner, importRepoCollab.RepositoryName, importRepoCollab.Username)
 		if err != nil {
 			zap.S().Errorf("Error arose removing permission for user %s  and repo %s", importRepoCollab.Username, importRepoCollab.RepositoryName)
+			failed++
+			continue
+		}
+		removed = append(removed, importRepoCollab)
+	}
+
+	// the removal succeeds even when the user keeps access through a team or
+	// the organization, so check what access is left
+	var persisting, unverified []persistingAccess
+	g.Progress().Start("Verifying removals", "grants", len(removed))
+	for _, importRepoCollab := range removed {
+		g.Progress().Step()
+		permission, sources, err := remainingAccess(owner, importRepoCollab.RepositoryName, importRepoCollab.Username, g)
+		if err != nil {
+			zap.S().Errorf("Error arose verifying removal for user %s and repo %s", importRepoCollab.Username, importRepoCollab.RepositoryName)
+			unverified = append(unverified, persistingAccess{repository: importRepoCollab.RepositoryName, username: importRepoCollab.Username, sources: err.Error()})
+			continue
+		}
+		if permission != "" {
+			persisting = append(persisting, persistingAccess{repository: importRepoCollab.RepositoryName, username: importRepoCollab.Username, accessLevel: permission, sources: sources})
+		}
+	}
+	g.Progress().Done()
+
+	if len(persisting) > 0 {
+		fmt.Println("Access persists for these repository collaborators, and must be removed from the source listed:")
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  REPOSITORY\tUSER\tACCESS\tSOURCE")
+		for _, p := range persisting {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", p.repository, p.username, p.accessLevel, p.sources)
+		}
+		if err = tw.Flush(); err != nil {
+			return err
 		}
 	}
+	if len(unverified) > 0 {
+		fmt.Println("The removals for these repository collaborators could not be verified, and their access must be checked:")
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  REPOSITORY\tUSER\tERROR")
+		for _, u := range unverified {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", u.repository, u.username, u.sources)
+		}
+		if err = tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d of %d grants and team memberships in %s", failed, grants+memberships, owner)
+	}
+	if len(unverified) > 0 {
+		return fmt.Errorf("could not verify %d of %d removed repository assignments in %s", len(unverified), len(removed), owner)
+	}
+	if len(persisting) > 0 {
+		return fmt.Errorf("access persists for %d of %d removed repository assignments in %s", len(persisting), len(removed), owner)
+	}
 
 	fmt.Printf("Successfully removed repository assignments for repository collaborators in: %s.", owner)
 	return nil
 }
+
+type persistingAccess struct {
+	repository  string
+	username    string
+	accessLevel string
+	sources     string
+}
+
+// remainingAccess looks up the access a user still has to a repository once
+// their repository grant is removed, returning the permission and where it
+// comes from, or empty strings when the access is gone.
+func remainingAccess(owner string, repo string, user string, g *utils.APIGetter) (string, string, error) {
+	repoInfo, err := g.GetRepoUserPermissions(owner, repo, user)
+	if err != nil {
+		return "", "", err
+	}
+	edge, ok, err := g.UserCollaboratorEdge(owner, *repoInfo, user)
+	if err != nil || !ok {
+		return "", "", err
+	}
+	return edge.Permission, utils.DescribeAccessSources(edge), nil
+}
+
+// checkMaxChanges refuses removals larger than --max-changes, guarding
+// against a file that revokes far more access than expected.
+func checkMaxChanges(cmdFlags *cmdFlags, changes int) error {
+	if cmdFlags.maxChanges > 0 && changes > cmdFlags.maxChanges {
+		return fmt.Errorf("refusing to make %d removals, more than --max-changes %d, no access was removed", changes, cmdFlags.maxChanges)
+	}
+	return nil
+}
+
+// confirmRemoval asks to go ahead with the removals unless --yes was given.
+// The answer can only be read from a terminal, so --yes is required when
+// stdin is the file being read or is not a terminal.
+func confirmRemoval(cmdFlags *cmdFlags, question string) (bool, error) {
+	if cmdFlags.yes {
+		return true, nil
+	}
+	if cmdFlags.fileName == utils.StdioFileName || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, errors.New("removals cannot be confirmed without a terminal, use --yes to remove access without confirming")
+	}
+	return utils.Confirm(os.Stdin, question)
+}
+
+type revocation struct {
+	repository   string
+	username     string
+	accessLevel  string
+	invitationId int
+}
+
+func runCmdRemoveUsers(owner string, cmdFlags *cmdFlags, g *utils.APIGetter) error {
+	var revocations []revocation
+	var repoNames []string
+	invitees := make(map[string]string)
+	// finish the status line if gathering access fails part way
+	defer g.Progress().Done()
+
+	g.Progress().Start("Gathering access", "users", len(cmdFlags.users))
+	for _, user := range cmdFlags.users {
+		g.Progress().Step()
+		invitees[strings.ToLower(user)] = user
+		zap.S().Debugf("Gathering repositories for username %s", user)
+		allRepoPerms, err := g.GetUserRepoPermissions(owner, user)
+		if err != nil {
+			zap.S().Errorf("Error arose gathering repositories for user %s", user)
+			return err
+		}
+		// every user query pages through the same organization repositories
+		collectNames := len(repoNames) == 0
+		for _, repo := range allRepoPerms {
+			if collectNames {
+				repoNames = append(repoNames, repo.Name)
+			}
+			edge, ok, err := g.UserCollaboratorEdge(owner, repo, user)
+			if err != nil {
+				zap.S().Errorf("Error arose gathering collaborators of repo %s", repo.Name)
+				return err
+			}
+			if ok && utils.IsDirectGrant(edge) {
+				revocations = append(revocations, revocation{repository: repo.Name, username: user, accessLevel: edge.Permission})
+			}
+		}
+	}
+
+	zap.S().Debugf("Gathering pending repository invitations")
+	g.Progress().Start("Gathering invitations", "repos", len(repoNames))
+	for _, repoName := range repoNames {
+		g.Progress().Step()
+		invitations, err := g.GetRepoInvitations(owner, repoName)
+		if err != nil {
+			zap.S().Errorf("Error arose gathering invitations for repo %s", repoName)
+			continue
+		}
+		for _, invitation := range invitations {
+			if user, ok := invitees[strings.ToLower(invitation.Invitee.Login)]; ok {
+				revocations = append(revocations, revocation{repository: repoName, username: user, accessLevel: invitation.Permission, invitationId: invitation.Id})
+			}
+		}
+	}
+
+	g.Progress().Done()
+
+	if len(revocations) == 0 {
+		fmt.Printf("No repository access or pending invitations found for %s in %s.", strings.Join(cmdFlags.users, ", "), owner)
+		return nil
+	}
+
+	for _, r := range revocations {
+		kind := "collaborator"
+		if r.invitationId != 0 {
+			kind = "invitation"
+		}
+		fmt.Fprintf(os.Stderr, "%s\t%s\t%s\t%s\n", r.username, r.repository, kind, r.accessLevel)
+	}
+	if err := checkMaxChanges(cmdFlags, len(revocations)); err != nil {
+		return err
+	}
+	confirmed, err := confirmRemoval(cmdFlags, fmt.Sprintf("Revoke %d grants and invitations for %d users?", len(revocations), len(cmdFlags.users)))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		fmt.Println("Aborted, no access was removed.")
+		return nil
+	}
+
+	resultsWriter, err := os.Create(cmdFlags.resultsFile)
+	if err != nil {
+		return err
+	}
+	defer resultsWriter.Close()
+	csvWriter := csv.NewWriter(resultsWriter)
+	err = csvWriter.Write([]string{"RepositoryName", "Username", "Type", "AccessLevel", "Status", "Error", "RemainingAccess"})
+	if err != nil {
+		zap.S().Error("Error raised in writing output", zap.Error(err))
+	}
+
+	persisting, failed, unverified := 0, 0, 0
+	g.Progress().Start("Removing access", "grants", len(revocations))
+	for _, r := range revocations {
+		g.Progress().Step()
+		kind := "collaborator"
+		if r.invitationId != 0 {
+			kind = "invitation"
+			zap.S().Debugf("Deleting invitation for %s to repo %s", r.username, r.repository)
+			err = g.DeleteRepoInvitation(owner, r.repository, r.invitationId)
+		} else {
+			zap.S().Debugf("Removing Repository Assignment for %s from repo %s", r.username, r.repository)
+			err = g.RemoveRepoCollaborator(owner, r.repository, r.username)
+		}
+		status, errMessage, remaining := "removed", "", ""
+		if err != nil {
+			zap.S().Errorf("Error arose removing %s for user %s and repo %s", kind, r.username, r.repository)
+			status, errMessage = "failed", err.Error()
+			failed++
+		} else if r.invitationId == 0 {
+			permission, sources, err := remainingAccess(owner, r.repository, r.username, g)
+			if err != nil {
+				zap.S().Errorf("Error arose verifying removal for user %s and repo %s", r.username, r.repository)
+				status, errMessage = "unverified", err.Error()
+				unverified++
+			} else if permission != "" {
+				status, remaining = "persists", permission+" from "+sources
+				persisting++
+			}
+		}
+		err = csvWriter.Write([]string{r.repository, r.username, kind, r.accessLevel, status, errMessage, remaining})
+		if err != nil {
+			zap.S().Error("Error raised in writing output", zap.Error(err))
+		}
+	}
+	csvWriter.Flush()
+	g.Progress().Done()
+	if err = csvWriter.Error(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to revoke %d of %d grants and invitations of %s in %s, see %s", failed, len(revocations), strings.Join(cmdFlags.users, ", "), owner, cmdFlags.resultsFile)
+	}
+	if unverified > 0 {
+		return fmt.Errorf("could not verify %d removed repository assignments of %s in %s, see %s", unverified, strings.Join(cmdFlags.users, ", "), owner, cmdFlags.resultsFile)
+	}
+	if persisting > 0 {
+		return fmt.Errorf("access persists for %d repository assignments of %s in %s through a team or the organization, see %s", persisting, strings.Join(cmdFlags.users, ", "), owner, cmdFlags.resultsFile)
+	}
+	fmt.Printf("Successfully removed repository access for %s in: %s. Results written to %s.", strings.Join(cmdFlags.users, ", "), owner, cmdFlags.resultsFile)
+	return nil
+}
@@ -0,0 +1,379 @@
+package review
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type applyCmdFlags struct {
+	token     string
+	hostname  string
+	fileNames []string
+	reviewer  string
+	auditFile string
+	manifest  string
+	dryRun    bool
+	debug     bool
+}
+
+func NewCmdApply() *cobra.Command {
+	cmdFlags := applyCmdFlags{}
+	var authToken string
+
+	applyCmd := &cobra.Command{
+		Use:   "apply [flags] <organization>",
+		Short: "Apply the decisions of access review workbooks.",
+		Long:  "Apply the keep, revoke and downgrade decisions of one or more access review workbooks, recording the reviewer, time and outcome of each in an audit file and reporting the owners yet to respond.",
+		Args:  cobra.MinimumNArgs(1),
+		// decisions that fail are reported as an error, which should not print usage
+		SilenceUsage: true,
+		RunE: func(applyCmd *cobra.Command, args []string) error {
+			var err error
+			var gqlClient api.GQLClient
+			var restClient api.RESTClient
+
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			if cmdFlags.token != "" {
+				authToken = cmdFlags.token
+			} else {
+				t, _ := auth.TokenForHost(cmdFlags.hostname)
+				authToken = t
+			}
+
+			// debug logging is also written to stderr, so it is not redrawn over
+			progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
+			restClient, err = gh.RESTClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving rest client")
+				return err
+			}
+
+			gqlClient, err = gh.GQLClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github.hawkgirl-preview+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving graphql client")
+				return err
+			}
+
+			owner := args[0]
+
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetProgress(progress)
+			return runCmdApply(owner, &cmdFlags, g)
+		},
+	}
+
+	// Configure flags for command
+
+	applyCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	applyCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	applyCmd.Flags().StringArrayVarP(&cmdFlags.fileNames, "from-file", "f", nil, "Path and Name of CSV review workbook, or directory of split workbooks, to apply (required, repeatable)")
+	applyCmd.Flags().StringVarP(&cmdFlags.reviewer, "reviewer", "r", "", "Name of the reviewer to record for rows without a Reviewer (default the authenticated user)")
+	applyCmd.Flags().StringVarP(&cmdFlags.auditFile, "audit-file", "a", "AccessReviewAudit.csv", "Name of CSV file to append the audit trail to")
+	applyCmd.Flags().StringVarP(&cmdFlags.manifest, "manifest", "m", "", "Path and Name of the manifest written by start --split-by, to report owners who have not returned their workbook (default the manifest in a --from-file directory)")
+	applyCmd.Flags().BoolVarP(&cmdFlags.dryRun, "dry-run", "", false, "Validate and report the decisions without applying or auditing them")
+	applyCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+	applyCmd.MarkFlagRequired("from-file")
+
+	return applyCmd
+}
+
+func runCmdApply(owner string, cmdFlags *applyCmdFlags, g *utils.APIGetter) error {
+	reviewFiles, err := expandReviewFiles(cmdFlags.fileNames)
+	if err != nil {
+		return err
+	}
+
+	manifests, err := readManifests(cmdFlags)
+	if err != nil {
+		return err
+	}
+
+	var decisions []data.ReviewDecision
+	for _, reviewFile := range reviewFiles {
+		fileDecisions, err := readReviewFile(reviewFile, g)
+		if err != nil {
+			return err
+		}
+		decisions = append(decisions, fileDecisions...)
+	}
+
+	// a dry run applies nothing, so there is nothing to audit
+	var audit *utils.AuditLog
+	if !cmdFlags.dryRun {
+		audit, err = utils.OpenAuditLog(cmdFlags.auditFile)
+		if err != nil {
+			zap.S().Errorf("Error arose opening audit file %s", cmdFlags.auditFile)
+			return err
+		}
+	}
+
+	outcomes := make(map[string]int)
+	pendingByOwner := make(map[string]int)
+	g.Progress().Start("Applying decisions", "rows", len(decisions))
+	for _, decision := range decisions {
+		g.Progress().Step()
+		if len(decision.Decision) == 0 {
+			outcomes["pending"]++
+			if len(decision.Owner) > 0 {
+				pendingByOwner[decision.Owner]++
+			}
+			continue
+		}
+		outcome, errMessage := applyDecision(owner, decision, cmdFlags.dryRun, g)
+		outcomes[outcome]++
+		if audit == nil {
+			continue
+		}
+		reviewer, err := decisionReviewer(decision, cmdFlags, g)
+		if err != nil {
+			audit.Close()
+			return err
+		}
+		if err = audit.Record(reviewer, decision, outcome, errMessage); err != nil {
+			zap.S().Error("Error raised in writing audit", zap.Error(err))
+		}
+	}
+
+	g.Progress().Done()
+	summary := fmt.Sprintf("%d kept, %d revoked, %d downgraded, %d stale, %d invalid, %d failed, %d dry-run, %d pending",
+		outcomes["kept"], outcomes["revoked"], outcomes["downgraded"], outcomes["stale"], outcomes["invalid"], outcomes["failed"], outcomes["dry-run"], outcomes["pending"])
+	if audit == nil {
+		fmt.Printf("Dry run of access review for %s: %s. No audit was written.\n", owner, summary)
+	} else {
+		if err = audit.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Applied access review for %s: %s. Audit written to %s.\n", owner, summary, cmdFlags.auditFile)
+	}
+	if len(pendingByOwner) > 0 {
+		owners := make([]string, 0, len(pendingByOwner))
+		for repoOwner := range pendingByOwner {
+			owners = append(owners, repoOwner)
+		}
+		sort.Strings(owners)
+		fmt.Println("Owners yet to respond:")
+		for _, repoOwner := range owners {
+			fmt.Printf("  %s (%d pending)\n", repoOwner, pendingByOwner[repoOwner])
+		}
+	}
+	if missing := missingWorkbooks(manifests, reviewFiles); len(missing) > 0 {
+		fmt.Println("Owners yet to return their workbook:")
+		for _, entry := range missing {
+			repoOwner := entry.Owner
+			if repoOwner == "" {
+				repoOwner = utils.UnownedReview
+			}
+			fmt.Printf("  %s (%s, %d grants)\n", repoOwner, entry.File, entry.Grants)
+		}
+	}
+	if outcomes["failed"] > 0 || outcomes["invalid"] > 0 {
+		return fmt.Errorf("%d decisions could not be applied", outcomes["failed"]+outcomes["invalid"])
+	}
+	return nil
+}
+
+// decisionReviewer is the reviewer to audit a decision under: the Reviewer of
+// its row, and otherwise --reviewer or the authenticated user running apply.
+// The Owner of the row is not a reviewer, as it may be a team or a topic.
+func decisionReviewer(decision data.ReviewDecision, cmdFlags *applyCmdFlags, g *utils.APIGetter) (string, error) {
+	switch {
+	case len(decision.Reviewer) > 0:
+		return decision.Reviewer, nil
+	case len(cmdFlags.reviewer) > 0:
+		return cmdFlags.reviewer, nil
+	}
+	user, err := g.GetAuthenticatedUser()
+	if err != nil {
+		zap.S().Errorf("Error arose retrieving the authenticated user, use --reviewer")
+		return "", err
+	}
+	// the authenticated user does not change between rows
+	cmdFlags.reviewer = user.Login
+	return user.Login, nil
+}
+
+// readManifests reads the --manifest, or otherwise the manifest of each
+// --from-file directory that has one.
+func readManifests(cmdFlags *applyCmdFlags) ([]data.ReviewManifest, error) {
+	manifestFiles := []string{cmdFlags.manifest}
+	if len(cmdFlags.manifest) == 0 {
+		manifestFiles = nil
+		for _, path := range cmdFlags.fileNames {
+			manifestFile := filepath.Join(path, utils.ReviewManifestFile)
+			if _, err := os.Stat(manifestFile); err == nil {
+				manifestFiles = append(manifestFiles, manifestFile)
+			}
+		}
+	}
+	var manifests []data.ReviewManifest
+	for _, manifestFile := range manifestFiles {
+		zap.S().Debugf("Reading review manifest %s", manifestFile)
+		manifest, err := utils.ReadReviewManifest(manifestFile)
+		if err != nil {
+			zap.S().Errorf("Error arose reading review manifest %s", manifestFile)
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+// missingWorkbooks returns the workbooks of the manifests that are not among
+// the review files being applied.
+func missingWorkbooks(manifests []data.ReviewManifest, reviewFiles []string) []data.ReviewManifestEntry {
+	returned := make(map[string]bool, len(reviewFiles))
+	for _, reviewFile := range reviewFiles {
+		returned[strings.ToLower(filepath.Base(reviewFile))] = true
+	}
+	var missing []data.ReviewManifestEntry
+	for _, manifest := range manifests {
+		for _, entry := range manifest.Workbooks {
+			if !returned[strings.ToLower(entry.File)] {
+				missing = append(missing, entry)
+			}
+		}
+	}
+	return missing
+}
+
+// expandReviewFiles expands directories in the given paths to the CSV review
+// workbooks they contain.
+func expandReviewFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(path, "*.csv"))
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no CSV review workbooks found in %s", path)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func readReviewFile(fileName string, g *utils.APIGetter) ([]data.ReviewDecision, error) {
+	f, err := os.Open(fileName)
+	zap.S().Debugf("Opening up file %s", fileName)
+	if err != nil {
+		zap.S().Errorf("Error arose opening review workbook")
+		return nil, err
+	}
+	defer f.Close()
+	reviewData, err := csv.NewReader(f).ReadAll()
+	zap.S().Debugf("Reading in all lines from csv file")
+	if err != nil {
+		zap.S().Errorf("Error arose reading decisions from review workbook %s", fileName)
+		return nil, err
+	}
+	if len(reviewData) == 0 {
+		return nil, fmt.Errorf("review workbook %s is empty", fileName)
+	}
+	return g.CreateReviewDecisionList(reviewData)
+}
+
+// applyDecision applies a single review decision through the same calls as
+// the add and remove commands, returning the outcome to audit.
+func applyDecision(owner string, decision data.ReviewDecision, dryRun bool, g *utils.APIGetter) (string, string) {
+	if err := utils.ValidateReviewDecision(decision); err != nil {
+		zap.S().Errorf("Invalid decision for user %s and repo %s: %v", decision.Username, decision.RepositoryName, err)
+		return "invalid", err.Error()
+	}
+	if decision.Decision == utils.DecisionKeep {
+		return "kept", ""
+	}
+
+	// the workbook may be weeks old, and applying it to access that has
+	// since changed could raise the access or invite the user back
+	repoInfo, err := g.GetRepoUserPermissions(owner, decision.RepositoryName, decision.Username)
+	if err != nil {
+		zap.S().Errorf("Error arose reading access for user %s and repo %s", decision.Username, decision.RepositoryName)
+		return "failed", err.Error()
+	}
+	edge, ok, err := g.UserCollaboratorEdge(owner, *repoInfo, decision.Username)
+	if err != nil {
+		zap.S().Errorf("Error arose reading access for user %s and repo %s", decision.Username, decision.RepositoryName)
+		return "failed", err.Error()
+	}
+	direct := ""
+	if ok {
+		direct, _, _ = utils.AccessSources(edge)
+	}
+	switch {
+	case direct == "":
+		zap.S().Debugf("Skipping %s on repo %s, no longer a direct collaborator", decision.Username, decision.RepositoryName)
+		return "stale", "no longer a direct collaborator"
+	case decision.Decision == utils.DecisionDowngrade && utils.PermissionRank(direct) <= utils.PermissionRank(decision.NewAccessLevel):
+		zap.S().Debugf("Skipping %s on repo %s, direct access %s is not above %s", decision.Username, decision.RepositoryName, direct, decision.NewAccessLevel)
+		return "stale", fmt.Sprintf("direct access is already %s", direct)
+	}
+	if dryRun {
+		return "dry-run", ""
+	}
+
+	outcome := "revoked"
+	if decision.Decision == utils.DecisionRevoke {
+		zap.S().Debugf("Removing Repository Assignment for %s from repo %s", decision.Username, decision.RepositoryName)
+		err = g.RemoveRepoCollaborator(owner, decision.RepositoryName, decision.Username)
+	} else {
+		outcome = "downgraded"
+		zap.S().Debugf("Downgrading %s on repo %s to %s", decision.Username, decision.RepositoryName, decision.NewAccessLevel)
+		var assignRepo []byte
+		assignRepo, err = json.Marshal(utils.CreateRepoPermData(utils.RESTPermission(decision.NewAccessLevel)))
+		if err == nil {
+			err = g.AddRepoCollaborator(owner, decision.RepositoryName, decision.Username, bytes.NewReader(assignRepo))
+		}
+	}
+	if err != nil {
+		zap.S().Errorf("Error arose applying %s for user %s and repo %s", decision.Decision, decision.Username, decision.RepositoryName)
+		return "failed", err.Error()
+	}
+	return outcome, ""
+}
@@ -0,0 +1,18 @@
+package review
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func NewCmdReview() *cobra.Command {
+	reviewCmd := &cobra.Command{
+		Use:   "review <command> [flags]",
+		Short: "Run access reviews of repository collaborators.",
+		Long:  "Generate access review workbooks for repository collaborator grants and apply the reviewers' decisions.",
+	}
+
+	reviewCmd.AddCommand(NewCmdStart())
+	reviewCmd.AddCommand(NewCmdApply())
+
+	return reviewCmd
+}
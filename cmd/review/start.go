@@ -0,0 +1,216 @@
+package review
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type startCmdFlags struct {
+	token      string
+	hostname   string
+	outputFile string
+	splitBy    string
+	debug      bool
+}
+
+func NewCmdStart() *cobra.Command {
+	cmdFlags := startCmdFlags{}
+	var authToken string
+
+	timestamp := time.Now().Format("20060102150405")
+	reviewFileDefault := fmt.Sprintf("AccessReview-%s.csv", timestamp)
+	reviewDirDefault := fmt.Sprintf("AccessReview-%s", timestamp)
+
+	startCmd := &cobra.Command{
+		Use:   "start [flags] <organization>",
+		Short: "Generate an access review workbook.",
+		Long:  "Generate an access review workbook with a row for every repository collaborator grant, to be filled in with a keep, revoke or downgrade decision.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(startCmd *cobra.Command, args []string) error {
+			var err error
+			var gqlClient api.GQLClient
+			var restClient api.RESTClient
+
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			if cmdFlags.token != "" {
+				authToken = cmdFlags.token
+			} else {
+				t, _ := auth.TokenForHost(cmdFlags.hostname)
+				authToken = t
+			}
+
+			// debug logging is also written to stderr, so it is not redrawn over
+			progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
+			restClient, err = gh.RESTClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving rest client")
+				return err
+			}
+
+			gqlClient, err = gh.GQLClient(&api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github.hawkgirl-preview+json",
+				},
+				Host:      cmdFlags.hostname,
+				AuthToken: authToken,
+				Transport: progress.Transport(nil),
+			})
+
+			if err != nil {
+				zap.S().Errorf("Error arose retrieving graphql client")
+				return err
+			}
+
+			switch cmdFlags.splitBy {
+			case "", utils.SplitByCodeowners, utils.SplitByTopic, utils.SplitByTeam:
+			default:
+				return fmt.Errorf("invalid --split-by %q, must be codeowners, topic or team", cmdFlags.splitBy)
+			}
+
+			owner := args[0]
+			g := utils.NewAPIGetter(gqlClient, restClient)
+			g.SetProgress(progress)
+
+			if len(cmdFlags.splitBy) > 0 {
+				if !startCmd.Flags().Changed("output-file") {
+					cmdFlags.outputFile = reviewDirDefault
+				}
+				return runCmdStartSplit(owner, &cmdFlags, g)
+			}
+
+			reviewWriter, err := os.Create(cmdFlags.outputFile)
+
+			if err != nil {
+				return err
+			}
+			defer reviewWriter.Close()
+
+			return runCmdStart(owner, g, reviewWriter)
+		},
+	}
+
+	// Configure flags for command
+
+	startCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	startCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	startCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", reviewFileDefault, "Name of file to write the CSV review workbook to, or of the directory to write split workbooks to")
+	startCmd.Flags().StringVarP(&cmdFlags.splitBy, "split-by", "", "", "Write one review workbook per repository owner: codeowners, topic or team")
+	startCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+
+	return startCmd
+}
+
+func runCmdStart(owner string, g *utils.APIGetter, reviewWriter io.Writer) error {
+	rows, err := g.GetReportRows(owner, utils.ReportOptions{})
+	if err != nil {
+		return err
+	}
+
+	zap.S().Debugf("Writing %d grants to review", len(rows))
+	if err = utils.WriteReviewWorkbook(reviewWriter, rows, ""); err != nil {
+		zap.S().Error("Error raised in writing output", zap.Error(err))
+		return err
+	}
+
+	fmt.Printf("Successfully generated access review of %d repository collaborator grants in %s", len(rows), owner)
+	return nil
+}
+
+// runCmdStartSplit writes a review workbook per repository owner to the
+// output directory, so each owner only reviews the grants they are
+// responsible for.
+func runCmdStartSplit(owner string, cmdFlags *startCmdFlags, g *utils.APIGetter) error {
+	rows, err := g.GetReportRows(owner, utils.ReportOptions{})
+	if err != nil {
+		return err
+	}
+
+	repoOwners := make(map[string]string)
+	rowsByOwner := make(map[string][]data.ReportRow)
+	var owners []string
+	for _, row := range rows {
+		if row.Team != "" {
+			continue
+		}
+		repoOwner, ok := repoOwners[row.RepositoryName]
+		if !ok {
+			zap.S().Debugf("Determining %s owner of repo %s", cmdFlags.splitBy, row.RepositoryName)
+			repoOwner, err = g.GetRepoOwner(owner, row.RepositoryName, cmdFlags.splitBy)
+			if err != nil {
+				// filing it as unowned would send its grants to the wrong people
+				zap.S().Errorf("Error arose determining owner of repo %s", row.RepositoryName)
+				return fmt.Errorf("determining %s owner of repo %s: %w", cmdFlags.splitBy, row.RepositoryName, err)
+			}
+			repoOwners[row.RepositoryName] = repoOwner
+		}
+		if _, ok := rowsByOwner[repoOwner]; !ok {
+			owners = append(owners, repoOwner)
+		}
+		rowsByOwner[repoOwner] = append(rowsByOwner[repoOwner], row)
+	}
+
+	if err = os.MkdirAll(cmdFlags.outputFile, 0755); err != nil {
+		return err
+	}
+	sort.Strings(owners)
+	fileNames := utils.ReviewFileNames(owners)
+	manifest := data.ReviewManifest{Organization: owner}
+	for _, repoOwner := range owners {
+		reviewFile := filepath.Join(cmdFlags.outputFile, fileNames[repoOwner])
+		zap.S().Debugf("Writing %d grants owned by %s to %s", len(rowsByOwner[repoOwner]), repoOwner, reviewFile)
+		reviewWriter, err := os.Create(reviewFile)
+		if err != nil {
+			return err
+		}
+		err = utils.WriteReviewWorkbook(reviewWriter, rowsByOwner[repoOwner], repoOwner)
+		reviewWriter.Close()
+		if err != nil {
+			zap.S().Error("Error raised in writing output", zap.Error(err))
+			return err
+		}
+		manifest.Workbooks = append(manifest.Workbooks, data.ReviewManifestEntry{Owner: repoOwner, File: fileNames[repoOwner], Grants: len(rowsByOwner[repoOwner])})
+	}
+
+	manifestFile := filepath.Join(cmdFlags.outputFile, utils.ReviewManifestFile)
+	zap.S().Debugf("Writing manifest of %d workbooks to %s", len(manifest.Workbooks), manifestFile)
+	manifestWriter, err := os.Create(manifestFile)
+	if err != nil {
+		return err
+	}
+	defer manifestWriter.Close()
+	if err = utils.WriteReviewManifest(manifestWriter, manifest); err != nil {
+		zap.S().Error("Error raised in writing output", zap.Error(err))
+		return err
+	}
+
+	fmt.Printf("Successfully generated access reviews of %d repository collaborator grants in %s for %d owners in %s", len(rows), owner, len(owners), cmdFlags.outputFile)
+	return nil
+}
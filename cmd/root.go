@@ -4,8 +4,17 @@ import (
 	"github.com/spf13/cobra"
 
 	addCmd "github.com/katiem0/gh-collaborators/cmd/add"
+	browseCmd "github.com/katiem0/gh-collaborators/cmd/browse"
+	checkCmd "github.com/katiem0/gh-collaborators/cmd/check"
+	diffCmd "github.com/katiem0/gh-collaborators/cmd/diff"
+	enforce2faCmd "github.com/katiem0/gh-collaborators/cmd/enforce2fa"
+	historyCmd "github.com/katiem0/gh-collaborators/cmd/history"
+	inactiveCmd "github.com/katiem0/gh-collaborators/cmd/inactive"
 	listCmd "github.com/katiem0/gh-collaborators/cmd/list"
+	promoteCmd "github.com/katiem0/gh-collaborators/cmd/promote"
 	removeCmd "github.com/katiem0/gh-collaborators/cmd/remove"
+	reviewCmd "github.com/katiem0/gh-collaborators/cmd/review"
+	statsCmd "github.com/katiem0/gh-collaborators/cmd/stats"
 )
 
 func NewCmdRoot() *cobra.Command {
@@ -17,8 +26,17 @@ func NewCmdRoot() *cobra.Command {
 	}
 
 	cmdRoot.AddCommand(addCmd.NewCmdAdd())
+	cmdRoot.AddCommand(browseCmd.NewCmdBrowse())
+	cmdRoot.AddCommand(checkCmd.NewCmdCheck())
+	cmdRoot.AddCommand(diffCmd.NewCmdDiff())
+	cmdRoot.AddCommand(enforce2faCmd.NewCmdEnforce2FA())
+	cmdRoot.AddCommand(historyCmd.NewCmdHistory())
+	cmdRoot.AddCommand(inactiveCmd.NewCmdInactive())
 	cmdRoot.AddCommand(listCmd.NewCmdList())
+	cmdRoot.AddCommand(promoteCmd.NewCmdPromote())
 	cmdRoot.AddCommand(removeCmd.NewCmdRemove())
+	cmdRoot.AddCommand(reviewCmd.NewCmdReview())
+	cmdRoot.AddCommand(statsCmd.NewCmdStats())
 	cmdRoot.CompletionOptions.DisableDefaultCmd = true
 	cmdRoot.SetHelpCommand(&cobra.Command{
 		Use:    "no-help",
@@ -0,0 +1,207 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cli/go-gh"
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/cli/go-gh/pkg/auth"
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/katiem0/gh-collaborators/internal/log"
+	"github.com/katiem0/gh-collaborators/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdFlags struct {
+	token      string
+	hostname   string
+	fileName   string
+	previous   string
+	store      string
+	top        int
+	format     string
+	outputFile string
+	debug      bool
+}
+
+func NewCmdStats() *cobra.Command {
+	cmdFlags := cmdFlags{}
+	var authToken string
+
+	statsCmd := &cobra.Command{
+		Use:   "stats [flags] <organization>",
+		Short: "Summarize repository collaborator access.",
+		Long:  "Summarize repository collaborator access, live or from a saved report, with counts per access level and visibility, the top guests and repositories, and the trend since a previous report.",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(statsCmd *cobra.Command, args []string) error {
+			var err error
+			var gqlClient api.GQLClient
+			var restClient api.RESTClient
+
+			// Reinitialize logging if debugging was enabled
+			if cmdFlags.debug {
+				logger, _ := log.NewLogger(cmdFlags.debug)
+				defer logger.Sync() // nolint:errcheck
+				zap.ReplaceGlobals(logger)
+			}
+
+			if cmdFlags.format != "text" && cmdFlags.format != "json" && cmdFlags.format != "markdown" {
+				return fmt.Errorf("unsupported format %q, must be one of text, json or markdown", cmdFlags.format)
+			}
+
+			// a saved report is summarized without calling the API
+			var g *utils.APIGetter
+			if len(cmdFlags.fileName) == 0 {
+				if cmdFlags.token != "" {
+					authToken = cmdFlags.token
+				} else {
+					t, _ := auth.TokenForHost(cmdFlags.hostname)
+					authToken = t
+				}
+
+				// debug logging is also written to stderr, so it is not redrawn over
+				progress := utils.NewProgress(os.Stderr, cmdFlags.debug)
+
+				restClient, err = gh.RESTClient(&api.ClientOptions{
+					Headers: map[string]string{
+						"Accept": "application/vnd.github+json",
+					},
+					Host:      cmdFlags.hostname,
+					AuthToken: authToken,
+					Transport: progress.Transport(nil),
+				})
+
+				if err != nil {
+					zap.S().Errorf("Error arose retrieving rest client")
+					return err
+				}
+
+				gqlClient, err = gh.GQLClient(&api.ClientOptions{
+					Headers: map[string]string{
+						"Accept": "application/vnd.github.hawkgirl-preview+json",
+					},
+					Host:      cmdFlags.hostname,
+					AuthToken: authToken,
+					Transport: progress.Transport(nil),
+				})
+
+				if err != nil {
+					zap.S().Errorf("Error arose retrieving graphql client")
+					return err
+				}
+				g = utils.NewAPIGetter(gqlClient, restClient)
+				g.SetProgress(progress)
+			}
+
+			owner := args[0]
+
+			if len(cmdFlags.outputFile) == 0 {
+				return runCmdStats(owner, &cmdFlags, g, os.Stdout)
+			}
+			// the summary is only moved into place once it has been written
+			// in full, so a failed run leaves any existing file untouched
+			statsWriter, err := utils.CreateAtomic(cmdFlags.outputFile)
+			if err != nil {
+				return err
+			}
+			defer statsWriter.Abort()
+			if err = runCmdStats(owner, &cmdFlags, g, statsWriter); err != nil {
+				return err
+			}
+			return statsWriter.Commit()
+		},
+	}
+
+	// Configure flags for command
+
+	statsCmd.PersistentFlags().StringVarP(&cmdFlags.token, "token", "t", "", `GitHub Personal Access Token (default "gh auth token")`)
+	statsCmd.PersistentFlags().StringVarP(&cmdFlags.hostname, "hostname", "", "github.com", "GitHub Enterprise Server hostname")
+	statsCmd.Flags().StringVarP(&cmdFlags.fileName, "from-file", "f", "", "Path and Name of CSV or JSON report to summarize instead of live access")
+	statsCmd.Flags().StringVarP(&cmdFlags.previous, "previous", "p", "", "Path and Name of an earlier CSV or JSON report to show the trend against")
+	statsCmd.Flags().StringVarP(&cmdFlags.store, "store", "", "", "Directory of a snapshot store to show the trend against its latest snapshot")
+	statsCmd.Flags().IntVarP(&cmdFlags.top, "top", "n", 10, "Number of guests and repositories to list")
+	statsCmd.Flags().StringVarP(&cmdFlags.format, "format", "", "text", "Output format: text, json or markdown")
+	statsCmd.Flags().StringVarP(&cmdFlags.outputFile, "output-file", "o", "", "Name of file to write the summary to (default stdout)")
+	statsCmd.PersistentFlags().BoolVarP(&cmdFlags.debug, "debug", "d", false, "To debug logging")
+	statsCmd.MarkFlagsMutuallyExclusive("previous", "store")
+
+	return statsCmd
+}
+
+func runCmdStats(owner string, cmdFlags *cmdFlags, g *utils.APIGetter, statsWriter io.Writer) error {
+	var rows []data.ReportRow
+	var err error
+	if len(cmdFlags.fileName) > 0 {
+		rows, err = readReportFile(cmdFlags.fileName)
+	} else {
+		rows, err = g.GetReportRows(owner, utils.ReportOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	zap.S().Debugf("Summarizing %d rows", len(rows))
+	stats := utils.ComputeStats(owner, rows, cmdFlags.top)
+
+	if len(cmdFlags.previous) > 0 {
+		previousRows, err := readReportFile(cmdFlags.previous)
+		if err != nil {
+			return err
+		}
+		utils.CompareStats(&stats, previousRows)
+	} else if len(cmdFlags.store) > 0 {
+		previousRows, err := latestSnapshot(owner, cmdFlags.store)
+		if err != nil {
+			return err
+		}
+		utils.CompareStats(&stats, previousRows)
+	}
+
+	switch cmdFlags.format {
+	case "json":
+		return utils.WriteStatsJSON(statsWriter, stats)
+	case "markdown":
+		return utils.WriteStatsMarkdown(statsWriter, stats)
+	default:
+		return utils.WriteStatsText(statsWriter, stats)
+	}
+}
+
+func latestSnapshot(owner string, dir string) ([]data.ReportRow, error) {
+	store, err := utils.ReadSnapshotStore(dir)
+	if err != nil {
+		zap.S().Errorf("Error arose opening snapshot store %s", dir)
+		return nil, err
+	}
+	defer store.Close()
+
+	snapshots, err := store.Snapshots(owner)
+	if err != nil {
+		zap.S().Errorf("Error arose reading snapshots for %s", owner)
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshots of %s found in %s, run list --store first", owner, dir)
+	}
+	latest := snapshots[len(snapshots)-1]
+	zap.S().Debugf("Comparing against snapshot taken %s", latest.TakenAt)
+	return latest.Rows, nil
+}
+
+func readReportFile(fileName string) ([]data.ReportRow, error) {
+	zap.S().Debugf("Opening up file %s", fileName)
+	f, err := os.Open(fileName)
+	if err != nil {
+		zap.S().Errorf("Error arose opening report file %s", fileName)
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := utils.ReadReport(f)
+	if err != nil {
+		zap.S().Errorf("Error arose reading report file %s", fileName)
+	}
+	return rows, err
+}
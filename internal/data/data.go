@@ -1,19 +1,57 @@
 package data
 
+import "time"
+
 type Edge struct {
-	Permission string
-	Node       struct {
+	Permission        string
+	PermissionSources []PermissionSource
+	Node              struct {
 		Login string
 	}
 }
 
+type PermissionSource struct {
+	Permission string
+	Source     struct {
+		Typename     string `graphql:"__typename"`
+		Organization struct {
+			Login string
+		} `graphql:"... on Organization"`
+		Repository struct {
+			NameWithOwner string
+		} `graphql:"... on Repository"`
+		Team struct {
+			Slug string
+		} `graphql:"... on Team"`
+	}
+}
+
 type RepoInfo struct {
-	DatabaseId    int    `json:"databaseId"`
-	Name          string `json:"name"`
-	Visibility    string `json:"visibility"`
+	DatabaseId       int       `json:"databaseId"`
+	Name             string    `json:"name"`
+	Visibility       string    `json:"visibility"`
+	IsArchived       bool      `json:"isArchived"`
+	IsFork           bool      `json:"isFork"`
+	PushedAt         time.Time `json:"pushedAt"`
+	DefaultBranchRef struct {
+		Name string `json:"name"`
+	} `json:"defaultBranchRef"`
+	PrimaryLanguage struct {
+		Name string `json:"name"`
+	} `json:"primaryLanguage"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `json:"name"`
+			} `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics" graphql:"repositoryTopics(first: 20)"`
 	Collaborators struct {
-		Edges []Edge
-	} `graphql:"collaborators(first:1, query: $user)"`
+		Edges    []Edge
+		PageInfo struct {
+			HasNextPage bool
+		}
+	} `graphql:"collaborators(first: 10, query: $user)"`
 }
 
 type OrganizationUserQuery struct {
@@ -24,14 +62,36 @@ type OrganizationUserQuery struct {
 				EndCursor   string
 				HasNextPage bool
 			}
-		} `graphql:"repositories(first: 100, after: $endCursor)"`
+		} `graphql:"repositories(first: 100, after: $endCursor, privacy: $privacy, isArchived: $isArchived)"`
 	} `graphql:"organization(login: $owner)"`
 }
 
+// RepositoryPrivacy is the GraphQL enum of PUBLIC or PRIVATE repositories.
+type RepositoryPrivacy string
+
+// RepoScope limits the repositories queried on the server, nil fields
+// matching every repository.
+type RepoScope struct {
+	Privacy    *RepositoryPrivacy
+	IsArchived *bool
+}
+
 type RepoSingleQuery struct {
 	Repository RepoInfo `graphql:"repository(owner: $owner, name: $name)"`
 }
 
+type RepoCollaboratorsQuery struct {
+	Repository struct {
+		Collaborators struct {
+			Edges    []Edge
+			PageInfo struct {
+				EndCursor   string
+				HasNextPage bool
+			}
+		} `graphql:"collaborators(first: 100, after: $endCursor, query: $user)"`
+	} `graphql:"repository(owner: $owner, name: $name)"`
+}
+
 type RepoCollaborators struct {
 	Login string `json:"login"`
 	Id    int    `json:"id"`
@@ -42,8 +102,235 @@ type ImportedRepoCollab struct {
 	RepositoryName string `json:"repositoryname"`
 	Username       string `json:"username"`
 	Permission     string `json:"accesslevel"`
+	Team           string `json:"team"`
+	TeamRole       string `json:"teamrole"`
 }
 
 type Permission struct {
 	Permission string `json:"permission"`
 }
+
+type Team struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type TeamMember struct {
+	Login string `json:"login"`
+	Role  string `json:"role"`
+}
+
+type TeamRole struct {
+	Role string `json:"role"`
+}
+
+type RepoInvitation struct {
+	Id         int       `json:"id"`
+	Permission string    `json:"permissions"`
+	CreatedAt  time.Time `json:"created_at"`
+	Invitee    struct {
+		Login string `json:"login"`
+	} `json:"invitee"`
+	Repository struct {
+		Name string `json:"name"`
+	} `json:"repository"`
+}
+
+type TeamRepositoriesQuery struct {
+	Organization struct {
+		Team struct {
+			Repositories struct {
+				Edges []struct {
+					Permission string
+					Node       struct {
+						Name string
+					}
+				}
+				PageInfo struct {
+					EndCursor   string
+					HasNextPage bool
+				}
+			} `graphql:"repositories(first: 100, after: $endCursor)"`
+		} `graphql:"team(slug: $slug)"`
+	} `graphql:"organization(login: $owner)"`
+}
+
+type User struct {
+	Login     string    `json:"login"`
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	Company   string    `json:"company"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OrgMembership struct {
+	State string `json:"state"`
+	Role  string `json:"role"`
+}
+
+type OrgInvitation struct {
+	InviteeId int    `json:"invitee_id"`
+	Role      string `json:"role"`
+	TeamIds   []int  `json:"team_ids,omitempty"`
+}
+
+// DateTime is the GraphQL DateTime scalar, an ISO-8601 encoded UTC date string.
+type DateTime struct {
+	time.Time
+}
+
+type ContributionRepository struct {
+	Repository struct {
+		Name  string
+		Owner struct {
+			Login string
+		}
+	}
+}
+
+type UserContributionsQuery struct {
+	User struct {
+		ContributionsCollection struct {
+			CommitContributionsByRepository            []ContributionRepository `graphql:"commitContributionsByRepository(maxRepositories: 100)"`
+			IssueContributionsByRepository             []ContributionRepository `graphql:"issueContributionsByRepository(maxRepositories: 100)"`
+			PullRequestContributionsByRepository       []ContributionRepository `graphql:"pullRequestContributionsByRepository(maxRepositories: 100)"`
+			PullRequestReviewContributionsByRepository []ContributionRepository `graphql:"pullRequestReviewContributionsByRepository(maxRepositories: 100)"`
+		} `graphql:"contributionsCollection(from: $from)"`
+	} `graphql:"user(login: $user)"`
+}
+
+type IssueSearch struct {
+	IssueCount int
+}
+
+type RepoActivitySearchQuery struct {
+	Authored IssueSearch `graphql:"authored: search(query: $authored, type: ISSUE, first: 1)"`
+	Reviewed IssueSearch `graphql:"reviewed: search(query: $reviewed, type: ISSUE, first: 1)"`
+}
+
+// ReportRow is a single row of a list report, keyed by the report column names
+// in both the CSV and JSON formats.
+type ReportRow struct {
+	RepositoryName     string `json:"RepositoryName"`
+	RepositoryID       string `json:"RepositoryID"`
+	Visibility         string `json:"Visibility"`
+	Username           string `json:"Username"`
+	AccessLevel        string `json:"AccessLevel"`
+	DirectAccess       string `json:"DirectAccess,omitempty"`
+	TeamAccess         string `json:"TeamAccess,omitempty"`
+	OrganizationAccess string `json:"OrganizationAccess,omitempty"`
+	Team               string `json:"Team,omitempty"`
+	TeamRole           string `json:"TeamRole,omitempty"`
+	IsArchived         string `json:"IsArchived,omitempty"`
+	IsFork             string `json:"IsFork,omitempty"`
+	PushedAt           string `json:"PushedAt,omitempty"`
+	DefaultBranch      string `json:"DefaultBranch,omitempty"`
+	Topics             string `json:"Topics,omitempty"`
+	Name               string `json:"Name,omitempty"`
+	Company            string `json:"Company,omitempty"`
+	Email              string `json:"Email,omitempty"`
+	CreatedAt          string `json:"CreatedAt,omitempty"`
+	TwoFactorEnabled   string `json:"TwoFactorEnabled,omitempty"`
+}
+
+type ReportChange struct {
+	Change            string `json:"Change"`
+	RepositoryID      string `json:"RepositoryID"`
+	RepositoryName    string `json:"RepositoryName"`
+	OldRepositoryName string `json:"OldRepositoryName,omitempty"`
+	Username          string `json:"Username"`
+	Team              string `json:"Team,omitempty"`
+	OldAccessLevel    string `json:"OldAccessLevel"`
+	NewAccessLevel    string `json:"NewAccessLevel"`
+}
+
+type Snapshot struct {
+	TakenAt time.Time
+	Rows    []ReportRow
+}
+
+type HistoryEvent struct {
+	Date time.Time `json:"Date"`
+	ReportChange
+}
+
+type Policy struct {
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule restricts the access of the users and repositories it selects.
+// Empty selectors match everything.
+type PolicyRule struct {
+	Name            string   `yaml:"name"`
+	Description     string   `yaml:"description"`
+	Users           []string `yaml:"users"`
+	ExcludeUsers    []string `yaml:"exclude_users"`
+	Repositories    []string `yaml:"repositories"`
+	DenyPermissions []string `yaml:"deny_permissions"`
+	MaxPermission   string   `yaml:"max_permission"`
+	DenyVisibility  []string `yaml:"deny_visibility"`
+	MaxReposPerUser int      `yaml:"max_repos_per_user"`
+	Remediation     string   `yaml:"remediation"`
+	DowngradeTo     string   `yaml:"downgrade_to"`
+}
+
+type PolicyViolation struct {
+	Rule           string `json:"Rule"`
+	RepositoryName string `json:"RepositoryName"`
+	RepositoryID   string `json:"RepositoryID"`
+	Visibility     string `json:"Visibility"`
+	Username       string `json:"Username"`
+	AccessLevel    string `json:"AccessLevel"`
+	Message        string `json:"Message"`
+	Remediation    string `json:"Remediation"`
+	NewAccessLevel string `json:"NewAccessLevel"`
+}
+
+// ReviewDecision is a row of an access review workbook.
+type ReviewDecision struct {
+	ImportedRepoCollab
+	Decision       string
+	NewAccessLevel string
+	Comment        string
+	Owner          string
+	Reviewer       string
+}
+
+// ReviewManifest lists the workbooks written by a split access review, so
+// owners who never return theirs can be reported.
+type ReviewManifest struct {
+	Organization string                `json:"Organization"`
+	Workbooks    []ReviewManifestEntry `json:"Workbooks"`
+}
+
+type ReviewManifestEntry struct {
+	Owner  string `json:"Owner"`
+	File   string `json:"File"`
+	Grants int    `json:"Grants"`
+}
+
+// StatsTotals are the headline counts of repository collaborator access.
+type StatsTotals struct {
+	Guests          int `json:"Guests"`
+	Repositories    int `json:"Repositories"`
+	Grants          int `json:"Grants"`
+	WriteGrants     int `json:"WriteGrants"`
+	GuestsWithWrite int `json:"GuestsWithWrite"`
+}
+
+type StatsCount struct {
+	Name  string `json:"Name"`
+	Count int    `json:"Count"`
+}
+
+type Stats struct {
+	Organization string `json:"Organization"`
+	StatsTotals
+	ByPermission    []StatsCount `json:"ByPermission"`
+	ByVisibility    []StatsCount `json:"ByVisibility"`
+	TopGuests       []StatsCount `json:"TopGuests"`
+	TopRepositories []StatsCount `json:"TopRepositories"`
+	Previous        *StatsTotals `json:"Previous,omitempty"`
+}
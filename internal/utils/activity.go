@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/shurcooL/graphql"
+)
+
+// contributionsMaxRepositories is the maxRepositories of each list of
+// contributions by repository in data.UserContributionsQuery, the most the
+// API allows.
+const contributionsMaxRepositories = 100
+
+// GetUserActiveRepos returns the names of the organization repositories the
+// user has committed to, opened issues or pull requests in, or reviewed pull
+// requests in since the given time. The contributions collection spans at
+// most one year and lists at most 100 repositories of each kind, so the
+// repositories are only complete when none of the lists reached that limit.
+// Otherwise, repositories that are not returned should be checked with
+// IsUserActiveInRepo.
+func (g *APIGetter) GetUserActiveRepos(owner string, user string, since time.Time) (map[string]bool, bool, error) {
+	query := new(data.UserContributionsQuery)
+	variables := map[string]interface{}{
+		"from": data.DateTime{Time: since.UTC()},
+		"user": graphql.String(user),
+	}
+	err := g.gqlClient.Query("getUserContributions", &query, variables)
+	if err != nil {
+		return nil, false, err
+	}
+
+	activeRepos := make(map[string]bool)
+	complete := true
+	contributions := query.User.ContributionsCollection
+	for _, byRepository := range [][]data.ContributionRepository{
+		contributions.CommitContributionsByRepository,
+		contributions.IssueContributionsByRepository,
+		contributions.PullRequestContributionsByRepository,
+		contributions.PullRequestReviewContributionsByRepository,
+	} {
+		if len(byRepository) >= contributionsMaxRepositories {
+			complete = false
+		}
+		for _, contribution := range byRepository {
+			if strings.EqualFold(contribution.Repository.Owner.Login, owner) {
+				activeRepos[contribution.Repository.Name] = true
+			}
+		}
+	}
+	return activeRepos, complete, nil
+}
+
+// IsUserActiveInRepo checks a single repository for the activity counted by
+// GetUserActiveRepos: commits to the default branch, issues or pull requests
+// opened, and pull requests reviewed since the given time.
+func (g *APIGetter) IsUserActiveInRepo(owner string, repo string, user string, since time.Time) (bool, error) {
+	url := fmt.Sprintf("repos/%s/%s/commits?author=%s&since=%s&per_page=1", owner, repo, user, since.UTC().Format(time.RFC3339))
+	var commits []json.RawMessage
+	err := g.restClient.Get(url, &commits)
+	var httpErr api.HTTPError
+	// an empty repository has no commits to list
+	if err != nil && !(errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusConflict) {
+		return false, err
+	}
+	if len(commits) > 0 {
+		return true, nil
+	}
+
+	query := new(data.RepoActivitySearchQuery)
+	qualifiers := fmt.Sprintf("repo:%s/%s", owner, repo)
+	date := since.UTC().Format(time.DateOnly)
+	variables := map[string]interface{}{
+		"authored": graphql.String(fmt.Sprintf("%s author:%s created:>=%s", qualifiers, user, date)),
+		"reviewed": graphql.String(fmt.Sprintf("%s type:pr reviewed-by:%s updated:>=%s", qualifiers, user, date)),
+	}
+	err = g.gqlClient.Query("getRepoActivity", &query, variables)
+	if err != nil {
+		return false, err
+	}
+	return query.Authored.IssueCount > 0 || query.Reviewed.IssueCount > 0, nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    string
+		commit      bool
+		abortAfter  bool
+		wantContent string
+		wantExists  bool
+	}{
+		{name: "commit creates the file", commit: true, wantContent: "new\n", wantExists: true},
+		{name: "commit replaces the file", existing: "old\n", commit: true, wantContent: "new\n", wantExists: true},
+		{name: "abort after commit keeps the file", commit: true, abortAfter: true, wantContent: "new\n", wantExists: true},
+		{name: "abort leaves the file untouched", existing: "old\n", wantContent: "old\n", wantExists: true},
+		{name: "abort does not create the file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fileName := filepath.Join(dir, "report.csv")
+			if tt.existing != "" {
+				if err := os.WriteFile(fileName, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			f, err := CreateAtomic(fileName)
+			if err != nil {
+				t.Fatalf("CreateAtomic() error = %v", err)
+			}
+			if _, err = f.WriteString("new\n"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.commit {
+				if err = f.Commit(); err != nil {
+					t.Fatalf("Commit() error = %v", err)
+				}
+			}
+			if !tt.commit || tt.abortAfter {
+				f.Abort()
+			}
+
+			content, err := os.ReadFile(fileName)
+			switch {
+			case !tt.wantExists:
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("ReadFile() error = %v, want the file to not exist", err)
+				}
+			case err != nil:
+				t.Errorf("ReadFile() error = %v", err)
+			case string(content) != tt.wantContent:
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				if entry.Name() != "report.csv" {
+					t.Errorf("temporary file %s was left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"go.uber.org/zap"
+)
+
+// ReportOptions controls which collaborators and columns GetReportRows
+// gathers.
+type ReportOptions struct {
+	// Usernames limit the report to these repository collaborators.
+	Usernames []string
+	// Explain fills in the DirectAccess, TeamAccess and OrganizationAccess
+	// columns.
+	Explain bool
+	// Teams adds a row for each team membership of the collaborators.
+	Teams bool
+	// Filter limits the repositories and grants in the report.
+	Filter RepoFilter
+	// Columns are the repository and user metadata columns to fill in.
+	Columns []string
+	// TwoFactorDisabled limits the report to collaborators without
+	// two-factor authentication.
+	TwoFactorDisabled bool
+	// Guests are the outside collaborators of the organization when they
+	// have already been gathered, otherwise they are gathered again.
+	Guests []data.RepoCollaborators
+}
+
+func (o ReportOptions) wantsColumn(name string) bool {
+	for _, column := range o.Columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (o ReportOptions) wantsUserMetadata() bool {
+	for _, column := range o.Columns {
+		if userMetadataColumns[column] {
+			return true
+		}
+	}
+	return false
+}
+
+// IncompleteReportError is returned with the rows of a report when some of
+// the lookups gathering it failed, so rows may be missing from it.
+type IncompleteReportError struct {
+	Errs []error
+}
+
+func (e *IncompleteReportError) Error() string {
+	return fmt.Sprintf("the report is incomplete, %d lookups failed: %v", len(e.Errs), errors.Join(e.Errs...))
+}
+
+func (e *IncompleteReportError) Unwrap() []error {
+	return e.Errs
+}
+
+// IsIncompleteReport reports whether GetReportRows returned rows with some
+// lookups failing, rather than failing to gather the report at all.
+func IsIncompleteReport(err error) bool {
+	var incomplete *IncompleteReportError
+	return errors.As(err, &incomplete)
+}
+
+// GetReportRows gathers a report row for every repository each outside
+// collaborator of the organization has access to. Lookups that fail for a
+// single user or team are collected into an IncompleteReportError returned
+// with the rows that were gathered.
+func (g *APIGetter) GetReportRows(owner string, options ReportOptions) ([]data.ReportRow, error) {
+	zap.S().Debugf("Gathering repositories and access for %s", owner)
+	var err error
+	repoCollaborators := options.Guests
+	if repoCollaborators == nil {
+		repoCollaborators, err = g.GetOrgGuests(owner)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	usernames := make(map[string]bool, len(options.Usernames))
+	for _, username := range options.Usernames {
+		usernames[strings.ToLower(username)] = true
+	}
+	var twoFactorDisabled map[string]bool
+	if options.TwoFactorDisabled || options.wantsColumn("TwoFactorEnabled") {
+		zap.S().Debugf("Gathering repository collaborators without two-factor authentication in %s", owner)
+		twoFactorDisabled, err = g.GetOrgGuestsWithout2FA(owner)
+		if err != nil {
+			// a blank column would be read as the status being unknown
+			zap.S().Error("Error raised in gathering two-factor authentication status, it requires organization owner access", zap.Error(err))
+			return nil, fmt.Errorf("gathering two-factor authentication status, which requires organization owner access: %w", err)
+		}
+	}
+
+	var selected []data.RepoCollaborators
+	for _, repoCollab := range repoCollaborators {
+		if len(usernames) > 0 && !usernames[strings.ToLower(repoCollab.Login)] {
+			continue
+		}
+		if options.TwoFactorDisabled && !twoFactorDisabled[repoCollab.Login] {
+			continue
+		}
+		selected = append(selected, repoCollab)
+	}
+
+	var rows []data.ReportRow
+	var errs []error
+	guests := make(map[string]bool)
+	g.progress.Start("Gathering access", "users", len(selected))
+	for _, repoCollab := range selected {
+		guests[repoCollab.Login] = true
+
+		zap.S().Debugf("Gathering repositories for username %s", repoCollab.Login)
+		allRepoPerms, err := g.GetScopedUserRepoPermissions(owner, repoCollab.Login, options.Filter.Scope())
+		if err != nil {
+			zap.S().Error("Error raised in gathering repositories and user permissions", zap.Error(err))
+			errs = append(errs, fmt.Errorf("gathering repositories of %s: %w", repoCollab.Login, err))
+		}
+		userRows, rowErrs := g.repoPermissionRows(owner, repoCollab.Login, allRepoPerms, options)
+		errs = append(errs, rowErrs...)
+
+		var user *data.User
+		if options.wantsUserMetadata() && len(userRows) > 0 {
+			zap.S().Debugf("Gathering profile of username %s", repoCollab.Login)
+			user, err = g.GetUser(repoCollab.Login)
+			if err != nil {
+				zap.S().Error("Error raised in gathering user profile", zap.Error(err))
+				errs = append(errs, fmt.Errorf("gathering profile of %s: %w", repoCollab.Login, err))
+				user = nil
+			}
+		}
+		for i := range userRows {
+			if user != nil {
+				userRows[i].Name = user.Name
+				userRows[i].Company = user.Company
+				userRows[i].Email = user.Email
+				if !user.CreatedAt.IsZero() {
+					userRows[i].CreatedAt = user.CreatedAt.Format(time.RFC3339)
+				}
+			}
+			if twoFactorDisabled != nil {
+				userRows[i].TwoFactorEnabled = strconv.FormatBool(!twoFactorDisabled[repoCollab.Login])
+			}
+		}
+		rows = append(rows, userRows...)
+		g.progress.Step()
+	}
+
+	if options.Teams {
+		teamRows, teamErrs := g.teamMembershipRows(owner, guests)
+		rows = append(rows, teamRows...)
+		errs = append(errs, teamErrs...)
+	}
+	g.progress.Done()
+	if len(errs) > 0 {
+		return rows, &IncompleteReportError{Errs: errs}
+	}
+	return rows, nil
+}
+
+// MissingGuests returns the usernames that are not among the outside
+// collaborators gathered with GetOrgGuests.
+func MissingGuests(repoCollaborators []data.RepoCollaborators, usernames []string) []string {
+	guests := make(map[string]bool, len(repoCollaborators))
+	for _, repoCollab := range repoCollaborators {
+		guests[strings.ToLower(repoCollab.Login)] = true
+	}
+	var missing []string
+	for _, username := range usernames {
+		if !guests[strings.ToLower(username)] {
+			missing = append(missing, username)
+		}
+	}
+	return missing
+}
+
+// ReadUsernames reads a file of one username per line, ignoring blank lines
+// and lines starting with #.
+func ReadUsernames(fileName string) ([]string, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var usernames []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		usernames = append(usernames, strings.TrimPrefix(line, "@"))
+	}
+	return usernames, nil
+}
+
+func (g *APIGetter) repoPermissionRows(owner string, username string, repos []data.RepoInfo, options ReportOptions) ([]data.ReportRow, []error) {
+	var rows []data.ReportRow
+	var errs []error
+	for _, repo := range repos {
+		edge, ok, err := g.UserCollaboratorEdge(owner, repo, username)
+		if err != nil {
+			zap.S().Error("Error raised in gathering repository collaborators", zap.Error(err))
+			errs = append(errs, fmt.Errorf("gathering collaborators of %s matching %s: %w", repo.Name, username, err))
+			continue
+		}
+		if !ok || !options.Filter.Matches(repo, edge.Permission) {
+			continue
+		}
+		row := data.ReportRow{
+			RepositoryName: repo.Name,
+			RepositoryID:   strconv.Itoa(repo.DatabaseId),
+			Visibility:     repo.Visibility,
+			Username:       username,
+			AccessLevel:    edge.Permission,
+		}
+		if options.Explain {
+			row.DirectAccess, row.TeamAccess, row.OrganizationAccess = AccessSources(edge)
+		}
+		for _, column := range options.Columns {
+			switch column {
+			case "IsArchived":
+				row.IsArchived = strconv.FormatBool(repo.IsArchived)
+			case "IsFork":
+				row.IsFork = strconv.FormatBool(repo.IsFork)
+			case "PushedAt":
+				if !repo.PushedAt.IsZero() {
+					row.PushedAt = repo.PushedAt.Format(time.RFC3339)
+				}
+			case "DefaultBranch":
+				row.DefaultBranch = repo.DefaultBranchRef.Name
+			case "Topics":
+				var topics []string
+				for _, node := range repo.RepositoryTopics.Nodes {
+					topics = append(topics, node.Topic.Name)
+				}
+				row.Topics = strings.Join(topics, ";")
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, errs
+}
+
+func (g *APIGetter) teamMembershipRows(owner string, guests map[string]bool) ([]data.ReportRow, []error) {
+	zap.S().Debugf("Gathering team memberships for repository collaborators in %s", owner)
+	teams, err := g.GetOrgTeams(owner)
+	if err != nil {
+		zap.S().Error("Error raised in gathering teams", zap.Error(err))
+		return nil, []error{fmt.Errorf("gathering teams: %w", err)}
+	}
+	var rows []data.ReportRow
+	var errs []error
+	g.progress.Start("Gathering team memberships", "teams", len(teams))
+	for _, team := range teams {
+		g.progress.Step()
+		members, err := g.GetTeamMembers(owner, team.Slug)
+		if err != nil {
+			zap.S().Error("Error raised in gathering team members", zap.Error(err))
+			errs = append(errs, fmt.Errorf("gathering members of team %s: %w", team.Slug, err))
+			continue
+		}
+		for _, member := range members {
+			if guests[member.Login] {
+				rows = append(rows, data.ReportRow{Username: member.Login, Team: team.Slug, TeamRole: member.Role})
+			}
+		}
+	}
+	return rows, errs
+}
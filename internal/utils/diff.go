@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+	ChangeRenamed = "renamed"
+)
+
+// ReportKey identifies a grant across reports by repository ID and username,
+// so that renamed repositories are matched. Team membership rows are keyed by
+// team instead, and rows without an ID fall back to the repository name.
+func ReportKey(row data.ReportRow) string {
+	user := strings.ToLower(row.Username)
+	if row.Team != "" {
+		return "team:" + strings.ToLower(row.Team) + "/" + user
+	}
+	if row.RepositoryID != "" {
+		return "id:" + row.RepositoryID + "/" + user
+	}
+	return "name:" + strings.ToLower(row.RepositoryName) + "/" + user
+}
+
+// GrantLevel returns the access level of a repository grant, or the role of
+// a team membership row. Reports written before the TeamRole column held the
+// role in AccessLevel.
+func GrantLevel(row data.ReportRow) string {
+	if row.Team != "" && row.TeamRole != "" {
+		return row.TeamRole
+	}
+	return row.AccessLevel
+}
+
+// MergeReports updates an existing report with the rows of a new one, keeping
+// existing rows that are not in the new report and replacing those that are.
+func MergeReports(existingRows []data.ReportRow, newRows []data.ReportRow) []data.ReportRow {
+	newByKey := make(map[string]data.ReportRow, len(newRows))
+	for _, row := range newRows {
+		newByKey[ReportKey(row)] = row
+	}
+	merged := make([]data.ReportRow, 0, len(existingRows)+len(newRows))
+	seen := make(map[string]bool, len(newRows))
+	for _, row := range existingRows {
+		key := ReportKey(row)
+		if newRow, ok := newByKey[key]; ok {
+			if !seen[key] {
+				merged = append(merged, newRow)
+				seen[key] = true
+			}
+			continue
+		}
+		merged = append(merged, row)
+	}
+	for _, row := range newRows {
+		if key := ReportKey(row); !seen[key] {
+			merged = append(merged, row)
+			seen[key] = true
+		}
+	}
+	return merged
+}
+
+// DiffReports compares two reports, returning the grants that were added,
+// removed, had their access level changed or had their repository renamed.
+func DiffReports(oldRows []data.ReportRow, newRows []data.ReportRow) []data.ReportChange {
+	oldByKey := make(map[string]data.ReportRow, len(oldRows))
+	for _, row := range oldRows {
+		oldByKey[ReportKey(row)] = row
+	}
+
+	var changes []data.ReportChange
+	seen := make(map[string]bool, len(newRows))
+	for _, row := range newRows {
+		key := ReportKey(row)
+		seen[key] = true
+		oldRow, ok := oldByKey[key]
+		change := data.ReportChange{
+			RepositoryID:   row.RepositoryID,
+			RepositoryName: row.RepositoryName,
+			Username:       row.Username,
+			Team:           row.Team,
+			NewAccessLevel: GrantLevel(row),
+		}
+		switch {
+		case !ok:
+			change.Change = ChangeAdded
+		case !strings.EqualFold(GrantLevel(oldRow), GrantLevel(row)):
+			change.Change = ChangeChanged
+			change.OldAccessLevel = GrantLevel(oldRow)
+		case oldRow.RepositoryName != row.RepositoryName:
+			change.Change = ChangeRenamed
+			change.OldAccessLevel = GrantLevel(oldRow)
+		default:
+			continue
+		}
+		if ok && oldRow.RepositoryName != row.RepositoryName {
+			change.OldRepositoryName = oldRow.RepositoryName
+		}
+		changes = append(changes, change)
+	}
+
+	for _, row := range oldRows {
+		if seen[ReportKey(row)] {
+			continue
+		}
+		changes = append(changes, data.ReportChange{
+			Change:         ChangeRemoved,
+			RepositoryID:   row.RepositoryID,
+			RepositoryName: row.RepositoryName,
+			Username:       row.Username,
+			Team:           row.Team,
+			OldAccessLevel: GrantLevel(row),
+		})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Change != changes[j].Change {
+			return changeOrder(changes[i].Change) < changeOrder(changes[j].Change)
+		}
+		if changes[i].RepositoryName != changes[j].RepositoryName {
+			return changes[i].RepositoryName < changes[j].RepositoryName
+		}
+		return changes[i].Username < changes[j].Username
+	})
+	return changes
+}
+
+func changeOrder(change string) int {
+	switch change {
+	case ChangeAdded:
+		return 0
+	case ChangeRemoved:
+		return 1
+	case ChangeChanged:
+		return 2
+	}
+	return 3
+}
+
+// ChangeTarget describes the repository or team a change applies to.
+func ChangeTarget(change data.ReportChange) string {
+	if change.Team != "" {
+		return "team " + change.Team
+	}
+	if change.OldRepositoryName != "" {
+		return fmt.Sprintf("%s (was %s)", change.RepositoryName, change.OldRepositoryName)
+	}
+	return change.RepositoryName
+}
+
+// WriteChangesText writes the changes grouped by type in a human readable form.
+func WriteChangesText(w io.Writer, changes []data.ReportChange) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "No changes in repository collaborator access.")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	headings := map[string]string{
+		ChangeAdded:   "Added",
+		ChangeRemoved: "Removed",
+		ChangeChanged: "Changed",
+		ChangeRenamed: "Renamed",
+	}
+	symbols := map[string]string{
+		ChangeAdded:   "+",
+		ChangeRemoved: "-",
+		ChangeChanged: "~",
+		ChangeRenamed: ">",
+	}
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.Change]++
+	}
+	current := ""
+	for _, change := range changes {
+		if change.Change != current {
+			current = change.Change
+			fmt.Fprintf(tw, "%s (%d):\n", headings[current], counts[current])
+		}
+		access := change.NewAccessLevel
+		switch change.Change {
+		case ChangeRemoved:
+			access = change.OldAccessLevel
+		case ChangeChanged:
+			access = change.OldAccessLevel + " -> " + change.NewAccessLevel
+		}
+		fmt.Fprintf(tw, "  %s %s\t%s\t%s\n", symbols[change.Change], change.Username, ChangeTarget(change), access)
+	}
+	return tw.Flush()
+}
+
+func WriteChangesCSV(w io.Writer, changes []data.ReportChange) error {
+	csvWriter := csv.NewWriter(w)
+	err := csvWriter.Write([]string{
+		"Change",
+		"RepositoryID",
+		"RepositoryName",
+		"OldRepositoryName",
+		"Username",
+		"Team",
+		"OldAccessLevel",
+		"NewAccessLevel",
+	})
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		err = csvWriter.Write([]string{
+			change.Change,
+			change.RepositoryID,
+			change.RepositoryName,
+			change.OldRepositoryName,
+			change.Username,
+			change.Team,
+			change.OldAccessLevel,
+			change.NewAccessLevel,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func WriteChangesJSON(w io.Writer, changes []data.ReportChange) error {
+	if changes == nil {
+		changes = []data.ReportChange{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(changes)
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+func TestDiffReports(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldRows []data.ReportRow
+		newRows []data.ReportRow
+		want    []data.ReportChange
+	}{
+		{
+			name:    "unchanged",
+			oldRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			newRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "Alice", AccessLevel: "write"}},
+		},
+		{
+			name:    "added and removed",
+			oldRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			newRows: []data.ReportRow{{RepositoryName: "repo-b", RepositoryID: "2", Username: "bob", AccessLevel: "READ"}},
+			want: []data.ReportChange{
+				{Change: ChangeAdded, RepositoryID: "2", RepositoryName: "repo-b", Username: "bob", NewAccessLevel: "READ"},
+				{Change: ChangeRemoved, RepositoryID: "1", RepositoryName: "repo-a", Username: "alice", OldAccessLevel: "WRITE"},
+			},
+		},
+		{
+			name:    "changed",
+			oldRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			newRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "ADMIN"}},
+			want: []data.ReportChange{
+				{Change: ChangeChanged, RepositoryID: "1", RepositoryName: "repo-a", Username: "alice", OldAccessLevel: "WRITE", NewAccessLevel: "ADMIN"},
+			},
+		},
+		{
+			name:    "renamed",
+			oldRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			newRows: []data.ReportRow{{RepositoryName: "repo-renamed", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			want: []data.ReportChange{
+				{Change: ChangeRenamed, RepositoryID: "1", RepositoryName: "repo-renamed", OldRepositoryName: "repo-a", Username: "alice", OldAccessLevel: "WRITE", NewAccessLevel: "WRITE"},
+			},
+		},
+		{
+			name:    "renamed and changed",
+			oldRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			newRows: []data.ReportRow{{RepositoryName: "repo-renamed", RepositoryID: "1", Username: "alice", AccessLevel: "READ"}},
+			want: []data.ReportChange{
+				{Change: ChangeChanged, RepositoryID: "1", RepositoryName: "repo-renamed", OldRepositoryName: "repo-a", Username: "alice", OldAccessLevel: "WRITE", NewAccessLevel: "READ"},
+			},
+		},
+		{
+			name:    "matched by name without an ID",
+			oldRows: []data.ReportRow{{RepositoryName: "Repo-A", Username: "alice", AccessLevel: "WRITE"}},
+			newRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			want: []data.ReportChange{
+				{Change: ChangeAdded, RepositoryID: "1", RepositoryName: "repo-a", Username: "alice", NewAccessLevel: "WRITE"},
+				{Change: ChangeRemoved, RepositoryName: "Repo-A", Username: "alice", OldAccessLevel: "WRITE"},
+			},
+		},
+		{
+			name:    "team role changed",
+			oldRows: []data.ReportRow{{Team: "eng", Username: "alice", TeamRole: "member"}},
+			newRows: []data.ReportRow{{Team: "eng", Username: "alice", TeamRole: "maintainer"}},
+			want: []data.ReportChange{
+				{Change: ChangeChanged, Team: "eng", Username: "alice", OldAccessLevel: "member", NewAccessLevel: "maintainer"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffReports(tt.oldRows, tt.newRows)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffReports() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeReports(t *testing.T) {
+	tests := []struct {
+		name         string
+		existingRows []data.ReportRow
+		newRows      []data.ReportRow
+		want         []data.ReportRow
+	}{
+		{
+			name:    "into an empty report",
+			newRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			want:    []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+		},
+		{
+			name: "updates rows in place and keeps the rest",
+			existingRows: []data.ReportRow{
+				{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"},
+				{RepositoryName: "repo-b", RepositoryID: "2", Username: "bob", AccessLevel: "READ"},
+			},
+			newRows: []data.ReportRow{
+				{RepositoryName: "repo-c", RepositoryID: "3", Username: "carol", AccessLevel: "ADMIN"},
+				{RepositoryName: "repo-a", RepositoryID: "1", Username: "Alice", AccessLevel: "READ"},
+			},
+			want: []data.ReportRow{
+				{RepositoryName: "repo-a", RepositoryID: "1", Username: "Alice", AccessLevel: "READ"},
+				{RepositoryName: "repo-b", RepositoryID: "2", Username: "bob", AccessLevel: "READ"},
+				{RepositoryName: "repo-c", RepositoryID: "3", Username: "carol", AccessLevel: "ADMIN"},
+			},
+		},
+		{
+			name:         "renamed repository replaces its row",
+			existingRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			newRows:      []data.ReportRow{{RepositoryName: "repo-renamed", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+			want:         []data.ReportRow{{RepositoryName: "repo-renamed", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"}},
+		},
+		{
+			name: "duplicate existing rows are merged once",
+			existingRows: []data.ReportRow{
+				{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"},
+				{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "WRITE"},
+			},
+			newRows: []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "ADMIN"}},
+			want:    []data.ReportRow{{RepositoryName: "repo-a", RepositoryID: "1", Username: "alice", AccessLevel: "ADMIN"}},
+		},
+		{
+			name:         "team rows are keyed by team",
+			existingRows: []data.ReportRow{{Team: "eng", Username: "alice", TeamRole: "member"}},
+			newRows: []data.ReportRow{
+				{Team: "eng", Username: "alice", TeamRole: "maintainer"},
+				{Team: "ops", Username: "alice", TeamRole: "member"},
+			},
+			want: []data.ReportRow{
+				{Team: "eng", Username: "alice", TeamRole: "maintainer"},
+				{Team: "ops", Username: "alice", TeamRole: "member"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeReports(tt.existingRows, tt.newRows)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeReports() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+const (
+	ArchivedInclude = "include"
+	ArchivedExclude = "exclude"
+	ArchivedOnly    = "only"
+)
+
+// RepoFilter selects the repositories and grants included in a report. Empty
+// fields match everything.
+type RepoFilter struct {
+	// Repo is a glob, or a regular expression between slashes, matched
+	// against the repository name.
+	Repo          string
+	Visibility    string
+	MinPermission string
+	Topic         string
+	Archived      string
+	Language      string
+
+	repoRegexp *regexp.Regexp
+}
+
+// Compile validates the filter, compiling a regular expression Repo.
+func (f *RepoFilter) Compile() error {
+	if len(f.Repo) > 2 && strings.HasPrefix(f.Repo, "/") && strings.HasSuffix(f.Repo, "/") {
+		re, err := regexp.Compile("(?i)" + f.Repo[1:len(f.Repo)-1])
+		if err != nil {
+			return fmt.Errorf("invalid repository regular expression %s: %w", f.Repo, err)
+		}
+		f.repoRegexp = re
+	}
+	switch strings.ToLower(f.Visibility) {
+	case "", "public", "private", "internal":
+	default:
+		return fmt.Errorf("unsupported visibility %q, must be one of public, private or internal", f.Visibility)
+	}
+	if len(f.MinPermission) > 0 && PermissionRank(f.MinPermission) == 0 {
+		return fmt.Errorf("unknown permission %q, must be one of read, triage, write, maintain or admin", f.MinPermission)
+	}
+	switch f.Archived {
+	case "", ArchivedInclude, ArchivedExclude, ArchivedOnly:
+	default:
+		return fmt.Errorf("unsupported archived filter %q, must be one of include, exclude or only", f.Archived)
+	}
+	return nil
+}
+
+// Scope returns the part of the filter that can be applied by the API.
+// Internal repositories are filtered locally, as the API can only limit
+// repositories to public or private.
+func (f *RepoFilter) Scope() data.RepoScope {
+	var scope data.RepoScope
+	switch strings.ToLower(f.Visibility) {
+	case "public", "private":
+		privacy := data.RepositoryPrivacy(strings.ToUpper(f.Visibility))
+		scope.Privacy = &privacy
+	}
+	switch f.Archived {
+	case ArchivedExclude, ArchivedOnly:
+		archived := f.Archived == ArchivedOnly
+		scope.IsArchived = &archived
+	}
+	return scope
+}
+
+// Matches reports whether a repository and a user's permission on it pass
+// the filter.
+func (f *RepoFilter) Matches(repo data.RepoInfo, permission string) bool {
+	switch {
+	case f.repoRegexp != nil:
+		if !f.repoRegexp.MatchString(repo.Name) {
+			return false
+		}
+	case len(f.Repo) > 0:
+		if !MatchesAny([]string{f.Repo}, repo.Name) {
+			return false
+		}
+	}
+	if len(f.Visibility) > 0 && !strings.EqualFold(f.Visibility, repo.Visibility) {
+		return false
+	}
+	if len(f.MinPermission) > 0 && PermissionRank(permission) < PermissionRank(f.MinPermission) {
+		return false
+	}
+	if (f.Archived == ArchivedExclude && repo.IsArchived) || (f.Archived == ArchivedOnly && !repo.IsArchived) {
+		return false
+	}
+	if len(f.Language) > 0 && !strings.EqualFold(f.Language, repo.PrimaryLanguage.Name) {
+		return false
+	}
+	if len(f.Topic) > 0 {
+		for _, node := range repo.RepositoryTopics.Nodes {
+			if strings.EqualFold(f.Topic, node.Topic.Name) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
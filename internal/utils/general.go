@@ -1,9 +1,14 @@
 package utils
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/cli/go-gh/pkg/api"
 	"github.com/katiem0/gh-collaborators/internal/data"
@@ -13,16 +18,38 @@ import (
 
 type Getter interface {
 	AddRepoCollaborator(owner string, repo string, username string, data io.Reader) error
-	CreateRepoCollaboratorsList(filedata [][]string) []data.ImportedRepoCollab
+	CreateRepoCollaboratorsList(filedata [][]string) ([]data.ImportedRepoCollab, error)
 	CreateRepoPermData(permission string) *data.Permission
 	GetGuestCollaborators(owner string) ([]byte, error)
-	GetOrgRepositoryPermissions(owner string, user string, endCursor *string) (*data.OrganizationUserQuery, error)
+	GetOrgRepositoryPermissions(owner string, user string, scope data.RepoScope, endCursor *string) (*data.OrganizationUserQuery, error)
+	GetUserRepoPermissions(owner string, user string) ([]data.RepoInfo, error)
+	GetScopedUserRepoPermissions(owner string, user string, scope data.RepoScope) ([]data.RepoInfo, error)
+	GetRepoUserPermissions(owner string, repo string, user string) (*data.RepoInfo, error)
+	GetRepoCollaboratorEdge(owner string, repo string, user string) (data.Edge, bool, error)
+	UserCollaboratorEdge(owner string, repo data.RepoInfo, user string) (data.Edge, bool, error)
 	RemoveRepoCollaborator(owner string, repo string, username string) error
+	GetUserActiveRepos(owner string, user string, since time.Time) (map[string]bool, bool, error)
+	IsUserActiveInRepo(owner string, repo string, user string, since time.Time) (bool, error)
+	GetRepoInvitations(owner string, repo string) ([]data.RepoInvitation, error)
+	DeleteRepoInvitation(owner string, repo string, id int) error
+	GetOrgTeams(owner string) ([]data.Team, error)
+	GetTeamMembers(owner string, team string) ([]data.TeamMember, error)
+	AddTeamMembership(owner string, team string, username string, role string) error
+	RemoveTeamMembership(owner string, team string, username string) error
+	GetTeam(owner string, team string) (*data.Team, error)
+	GetTeamRepoPermissions(owner string, team string) (map[string]string, error)
+	AddTeamRepoPermission(owner string, team string, repo string, permission string) error
+	GetUser(username string) (*data.User, error)
+	GetOrgMembership(owner string, username string) (*data.OrgMembership, error)
+	CreateOrgInvitation(owner string, invitation data.OrgInvitation) error
+	GetOrgGuestsWithout2FA(owner string) (map[string]bool, error)
+	RemoveOrgOutsideCollaborator(owner string, username string) error
 }
 
 type APIGetter struct {
 	gqlClient  api.GQLClient
 	restClient api.RESTClient
+	progress   *Progress
 }
 
 func NewAPIGetter(gqlClient api.GQLClient, restClient api.RESTClient) *APIGetter {
@@ -32,54 +59,302 @@ func NewAPIGetter(gqlClient api.GQLClient, restClient api.RESTClient) *APIGetter
 	}
 }
 
+// SetProgress reports the progress of long running requests to p.
+func (g *APIGetter) SetProgress(p *Progress) {
+	g.progress = p
+}
+
+// Progress returns the progress the getter reports to, which may be nil.
+func (g *APIGetter) Progress() *Progress {
+	return g.progress
+}
+
 func (g *APIGetter) GetOrgGuestCollaborators(owner string) ([]byte, error) {
-	url := fmt.Sprintf("orgs/%s/outside_collaborators", owner)
+	url := fmt.Sprintf("orgs/%s/outside_collaborators?per_page=100", owner)
 	zap.S().Debugf("Reading in repository collaborators from %v", url)
-	resp, err := g.restClient.Request("GET", url, nil)
+	var collaborators []json.RawMessage
+	err := g.GetPaginated(url, func(body []byte) error {
+		var page []json.RawMessage
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		collaborators = append(collaborators, page...)
+		return nil
+	})
 	if err != nil {
 		log.Printf("Body read error, %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-	responseData, err := io.ReadAll(resp.Body)
+	return json.Marshal(collaborators)
+}
+
+// GetOrgGuests gathers the outside collaborators of the organization.
+func (g *APIGetter) GetOrgGuests(owner string) ([]data.RepoCollaborators, error) {
+	repoCollabList, err := g.GetOrgGuestCollaborators(owner)
 	if err != nil {
-		log.Printf("Body read error, %v", err)
+		zap.S().Error("Error raised in gathering users", zap.Error(err))
+		return nil, err
 	}
-	return responseData, err
+
+	var repoCollaborators []data.RepoCollaborators
+	err = json.Unmarshal(repoCollabList, &repoCollaborators)
+	return repoCollaborators, err
 }
 
-func (g *APIGetter) GetOrgRepositoryPermissions(owner string, user string, endCursor *string) (*data.OrganizationUserQuery, error) {
+// GetPaginated requests every page of a REST list endpoint, following the
+// Link header, and passes the body of each page to handle.
+func (g *APIGetter) GetPaginated(url string, handle func(body []byte) error) error {
+	for url != "" {
+		zap.S().Debugf("Reading page from %v", url)
+		resp, err := g.restClient.Request("GET", url, nil)
+		if err != nil {
+			return err
+		}
+		responseData, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if err = handle(responseData); err != nil {
+			return err
+		}
+		url = nextPage(resp.Header.Get("Link"))
+	}
+	return nil
+}
+
+var linkNextRE = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+func nextPage(link string) string {
+	match := linkNextRE.FindStringSubmatch(link)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func (g *APIGetter) GetOrgRepositoryPermissions(owner string, user string, scope data.RepoScope, endCursor *string) (*data.OrganizationUserQuery, error) {
 	query := new(data.OrganizationUserQuery)
 	variables := map[string]interface{}{
-		"endCursor": (*graphql.String)(endCursor),
-		"owner":     graphql.String(owner),
-		"user":      graphql.String(user),
+		"endCursor":  (*graphql.String)(endCursor),
+		"owner":      graphql.String(owner),
+		"user":       graphql.String(user),
+		"privacy":    scope.Privacy,
+		"isArchived": (*graphql.Boolean)(scope.IsArchived),
 	}
 	err := g.gqlClient.Query("getOrganizationRepoPermissions", &query, variables)
 
 	return query, err
 }
 
-func (g *APIGetter) CreateRepoCollaboratorsList(filedata [][]string) []data.ImportedRepoCollab {
+// GetUserRepoPermissions pages through every repository in the organization,
+// returning each one with the collaborator edge matching the user, if any.
+func (g *APIGetter) GetUserRepoPermissions(owner string, user string) ([]data.RepoInfo, error) {
+	return g.GetScopedUserRepoPermissions(owner, user, data.RepoScope{})
+}
+
+// GetScopedUserRepoPermissions is GetUserRepoPermissions limited to the
+// repositories within scope.
+func (g *APIGetter) GetScopedUserRepoPermissions(owner string, user string, scope data.RepoScope) ([]data.RepoInfo, error) {
+	var reposCursor *string
+	var allRepoPerms []data.RepoInfo
+	for {
+		repoUserPermissions, err := g.GetOrgRepositoryPermissions(owner, user, scope, reposCursor)
+		if err != nil {
+			return allRepoPerms, err
+		}
+		allRepoPerms = append(allRepoPerms, repoUserPermissions.Organization.Repositories.Nodes...)
+		g.progress.AddRepos(len(repoUserPermissions.Organization.Repositories.Nodes))
+		if !repoUserPermissions.Organization.Repositories.PageInfo.HasNextPage {
+			break
+		}
+		reposCursor = &repoUserPermissions.Organization.Repositories.PageInfo.EndCursor
+	}
+	return allRepoPerms, nil
+}
+
+// GetRepoUserPermissions returns a single repository with the collaborator
+// edge matching the user, if they have access to it.
+func (g *APIGetter) GetRepoUserPermissions(owner string, repo string, user string) (*data.RepoInfo, error) {
+	query := new(data.RepoSingleQuery)
+	variables := map[string]interface{}{
+		"owner": graphql.String(owner),
+		"name":  graphql.String(repo),
+		"user":  graphql.String(user),
+	}
+	err := g.gqlClient.Query("getRepoUserPermissions", &query, variables)
+
+	return &query.Repository, err
+}
+
+// GetRepoCollaboratorEdge pages through the collaborators of a repository
+// matching the user, returning the edge with exactly their login.
+func (g *APIGetter) GetRepoCollaboratorEdge(owner string, repo string, user string) (data.Edge, bool, error) {
+	var endCursor *string
+	for {
+		query := new(data.RepoCollaboratorsQuery)
+		variables := map[string]interface{}{
+			"endCursor": (*graphql.String)(endCursor),
+			"owner":     graphql.String(owner),
+			"name":      graphql.String(repo),
+			"user":      graphql.String(user),
+		}
+		err := g.gqlClient.Query("getRepoCollaborators", &query, variables)
+		if err != nil {
+			return data.Edge{}, false, err
+		}
+		collaborators := query.Repository.Collaborators
+		for _, edge := range collaborators.Edges {
+			if strings.EqualFold(edge.Node.Login, user) {
+				return edge, true, nil
+			}
+		}
+		if !collaborators.PageInfo.HasNextPage {
+			return data.Edge{}, false, nil
+		}
+		endCursor = &collaborators.PageInfo.EndCursor
+	}
+}
+
+// UserCollaboratorEdge returns the collaborator edge of the user in a
+// repository returned by GetUserRepoPermissions. Only the first logins
+// partially matching the user are returned with the repository, so the rest
+// are paged through when the user is not among them.
+func (g *APIGetter) UserCollaboratorEdge(owner string, repo data.RepoInfo, user string) (data.Edge, bool, error) {
+	if edge, ok := CollaboratorEdge(repo, user); ok || !repo.Collaborators.PageInfo.HasNextPage {
+		return edge, ok, nil
+	}
+	zap.S().Debugf("Paging through collaborators of repo %s matching %s", repo.Name, user)
+	return g.GetRepoCollaboratorEdge(owner, repo.Name, user)
+}
+
+// CreateRepoCollaboratorsList reads the grants and team memberships to add,
+// requiring a header to name a Username column and a RepositoryName column
+// with its AccessLevel, a Team column, or both.
+func (g *APIGetter) CreateRepoCollaboratorsList(filedata [][]string) ([]data.ImportedRepoCollab, error) {
 	//convert csv lines to array of structs
 	var importRepoCollabs []data.ImportedRepoCollab
-	var repoCollab data.ImportedRepoCollab
-	for _, each := range filedata[1:] {
-		repoCollab.RepositoryName = each[0]
-		repoCollab.Username = each[1]
-		repoCollab.Permission = each[2]
+	columns, records := importColumns(filedata)
+	if err := requireColumns(columns, "username"); err != nil {
+		return nil, err
+	}
+	if err := requireTarget(columns); err != nil {
+		return nil, err
+	}
+	if _, ok := columns["repositoryname"]; ok {
+		if err := requireColumns(columns, "accesslevel"); err != nil {
+			return nil, err
+		}
+	}
+	for _, each := range records {
+		var repoCollab data.ImportedRepoCollab
+		repoCollab.RepositoryName = columnValue(each, columns, "repositoryname")
+		repoCollab.Username = columnValue(each, columns, "username")
+		repoCollab.Permission = columnValue(each, columns, "accesslevel")
+		repoCollab.Team = columnValue(each, columns, "team")
+		repoCollab.TeamRole = columnValue(each, columns, "teamrole")
 		importRepoCollabs = append(importRepoCollabs, repoCollab)
 	}
-	return importRepoCollabs
+	return importRepoCollabs, nil
+}
+
+// importColumnNames are the columns read from imported files, keyed by their
+// lower case name.
+var importColumnNames = map[string]string{
+	"repositoryname": "RepositoryName",
+	"username":       "Username",
+	"accesslevel":    "AccessLevel",
+	"team":           "Team",
+	"teamrole":       "TeamRole",
+	"decision":       "Decision",
+	"newaccesslevel": "NewAccessLevel",
+	"comment":        "Comment",
+	"owner":          "Owner",
+	"reviewer":       "Reviewer",
 }
+
+// importColumns maps the known column names of an imported csv file to their
+// position, returning the records after the header. A first line naming none
+// of them is only read as a record when its access level is a known
+// permission, in the layout of a list report, such as lines filtered from a
+// report with grep, or in the RepositoryName,Username,AccessLevel layout.
+// Otherwise it is skipped as a header and the records are read in the
+// RepositoryName,Username,AccessLevel layout.
+func importColumns(filedata [][]string) (map[string]int, [][]string) {
+	if len(filedata) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range filedata[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := importColumnNames[name]; ok {
+			columns[name] = i
+		}
+	}
+	if len(columns) > 0 {
+		return columns, filedata[1:]
+	}
+	listLayout := map[string]int{"repositoryname": 0, "username": 3, "accesslevel": 4}
+	if len(filedata[0]) >= len(BaseReportColumns) && PermissionRank(columnValue(filedata[0], listLayout, "accesslevel")) > 0 {
+		return listLayout, filedata
+	}
+	plainLayout := map[string]int{"repositoryname": 0, "username": 1, "accesslevel": 2}
+	if PermissionRank(columnValue(filedata[0], plainLayout, "accesslevel")) > 0 {
+		return plainLayout, filedata
+	}
+	return plainLayout, filedata[1:]
+}
+
+// requireColumns returns an error naming the columns missing from the
+// header of an imported file, as their values would otherwise be read as
+// empty.
+func requireColumns(columns map[string]int, names ...string) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	var missing []string
+	for _, name := range names {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, importColumnNames[name])
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required column %s in header", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// requireTarget returns an error when the header of an imported file names
+// neither a RepositoryName nor a Team column.
+func requireTarget(columns map[string]int) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	_, hasRepo := columns["repositoryname"]
+	_, hasTeam := columns["team"]
+	if !hasRepo && !hasTeam {
+		return errors.New("missing required column RepositoryName or Team in header")
+	}
+	return nil
+}
+
+func columnValue(record []string, columns map[string]int, name string) string {
+	i, ok := columns[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
 func (g *APIGetter) AddRepoCollaborator(owner string, repo string, username string, data io.Reader) error {
 	url := fmt.Sprintf("repos/%s/%s/collaborators/%s", owner, repo, username)
 
 	resp, err := g.restClient.Request("PUT", url, data)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer resp.Body.Close()
-	return err
+	return nil
 }
 
 func CreateRepoPermData(permission string) *data.Permission {
@@ -89,16 +364,24 @@ func CreateRepoPermData(permission string) *data.Permission {
 	return &s
 }
 
-func (g *APIGetter) DeleteRepoCollaboratorsList(filedata [][]string) []data.ImportedRepoCollab {
+func (g *APIGetter) DeleteRepoCollaboratorsList(filedata [][]string) ([]data.ImportedRepoCollab, error) {
 	//convert csv lines to array of structs
 	var importRepoCollabs []data.ImportedRepoCollab
-	var repoCollab data.ImportedRepoCollab
-	for _, each := range filedata[1:] {
-		repoCollab.RepositoryName = each[0]
-		repoCollab.Username = each[1]
+	columns, records := importColumns(filedata)
+	if err := requireColumns(columns, "username"); err != nil {
+		return nil, err
+	}
+	if err := requireTarget(columns); err != nil {
+		return nil, err
+	}
+	for _, each := range records {
+		var repoCollab data.ImportedRepoCollab
+		repoCollab.RepositoryName = columnValue(each, columns, "repositoryname")
+		repoCollab.Username = columnValue(each, columns, "username")
+		repoCollab.Team = columnValue(each, columns, "team")
 		importRepoCollabs = append(importRepoCollabs, repoCollab)
 	}
-	return importRepoCollabs
+	return importRepoCollabs, nil
 }
 
 func (g *APIGetter) RemoveRepoCollaborator(owner string, repo string, username string) error {
@@ -106,8 +389,69 @@ func (g *APIGetter) RemoveRepoCollaborator(owner string, repo string, username s
 
 	resp, err := g.restClient.Request("DELETE", url, nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+func (g *APIGetter) GetRepoInvitations(owner string, repo string) ([]data.RepoInvitation, error) {
+	var invitations []data.RepoInvitation
+	url := fmt.Sprintf("repos/%s/%s/invitations?per_page=100", owner, repo)
+	err := g.GetPaginated(url, func(body []byte) error {
+		var page []data.RepoInvitation
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		invitations = append(invitations, page...)
+		return nil
+	})
+	return invitations, err
+}
+
+// GetOrgRepoInvitations lists the pending invitations to every repository in
+// the organization.
+func (g *APIGetter) GetOrgRepoInvitations(owner string) ([]data.RepoInvitation, error) {
+	var repos []struct {
+		Name string `json:"name"`
+	}
+	url := fmt.Sprintf("orgs/%s/repos?per_page=100", owner)
+	zap.S().Debugf("Reading in repositories from %v", url)
+	err := g.GetPaginated(url, func(body []byte) error {
+		var page []struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		repos = append(repos, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var invitations []data.RepoInvitation
+	g.progress.Start("Gathering invitations", "repos", len(repos))
+	defer g.progress.Done()
+	for _, repo := range repos {
+		repoInvitations, err := g.GetRepoInvitations(owner, repo.Name)
+		if err != nil {
+			return invitations, err
+		}
+		invitations = append(invitations, repoInvitations...)
+		g.progress.Step()
+	}
+	return invitations, nil
+}
+
+func (g *APIGetter) DeleteRepoInvitation(owner string, repo string, id int) error {
+	url := fmt.Sprintf("repos/%s/%s/invitations/%d", owner, repo, id)
+
+	resp, err := g.restClient.Request("DELETE", url, nil)
+	if err != nil {
+		return err
 	}
 	defer resp.Body.Close()
-	return err
+	return nil
 }
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImportColumns(t *testing.T) {
+	plainLayout := map[string]int{"repositoryname": 0, "username": 1, "accesslevel": 2}
+	listLayout := map[string]int{"repositoryname": 0, "username": 3, "accesslevel": 4}
+	tests := []struct {
+		name        string
+		filedata    [][]string
+		wantColumns map[string]int
+		wantRecords [][]string
+	}{
+		{
+			name: "empty file",
+		},
+		{
+			name:        "header in any order and case",
+			filedata:    [][]string{{" username ", "AccessLevel", "REPOSITORYNAME"}, {"alice", "write", "api"}},
+			wantColumns: map[string]int{"username": 0, "accesslevel": 1, "repositoryname": 2},
+			wantRecords: [][]string{{"alice", "write", "api"}},
+		},
+		{
+			name:        "list report header",
+			filedata:    [][]string{{"RepositoryName", "RepositoryID", "Visibility", "Username", "AccessLevel"}, {"api", "1", "private", "alice", "WRITE"}},
+			wantColumns: listLayout,
+			wantRecords: [][]string{{"api", "1", "private", "alice", "WRITE"}},
+		},
+		{
+			name:        "team membership header",
+			filedata:    [][]string{{"Team", "Username", "TeamRole"}, {"eng", "alice", "member"}},
+			wantColumns: map[string]int{"team": 0, "username": 1, "teamrole": 2},
+			wantRecords: [][]string{{"eng", "alice", "member"}},
+		},
+		{
+			name:        "list report rows without a header",
+			filedata:    [][]string{{"api", "1", "private", "alice", "WRITE"}, {"web", "2", "public", "bob", "read"}},
+			wantColumns: listLayout,
+			wantRecords: [][]string{{"api", "1", "private", "alice", "WRITE"}, {"web", "2", "public", "bob", "read"}},
+		},
+		{
+			name:        "plain rows without a header",
+			filedata:    [][]string{{"api", "alice", "maintain"}, {"web", "bob", "read"}},
+			wantColumns: plainLayout,
+			wantRecords: [][]string{{"api", "alice", "maintain"}, {"web", "bob", "read"}},
+		},
+		{
+			name:        "unknown header is skipped",
+			filedata:    [][]string{{"Repo", "Login", "Role"}, {"api", "alice", "write"}},
+			wantColumns: plainLayout,
+			wantRecords: [][]string{{"api", "alice", "write"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, records := importColumns(tt.filedata)
+			if !reflect.DeepEqual(columns, tt.wantColumns) {
+				t.Errorf("importColumns() columns = %v, want %v", columns, tt.wantColumns)
+			}
+			if !reflect.DeepEqual(records, tt.wantRecords) {
+				t.Errorf("importColumns() records = %q, want %q", records, tt.wantRecords)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+type htmlBar struct {
+	Name    string
+	Count   int
+	Percent int
+}
+
+type htmlGroup struct {
+	Name        string
+	Visibility  string
+	Count       int
+	AccessLevel string
+	Grants      string
+}
+
+type htmlReport struct {
+	Organization    string
+	GeneratedAt     string
+	Stats           data.Stats
+	ByPermission    []htmlBar
+	ByVisibility    []htmlBar
+	Users           []htmlGroup
+	Repositories    []htmlGroup
+	Columns         []string
+	Rows            [][]string
+	TeamMemberships [][]string
+}
+
+// WriteReportHTML writes the report as a single HTML page with no external
+// assets: summary charts, and sortable, filterable tables of access per user,
+// per repository and of every grant with the named columns.
+func WriteReportHTML(w io.Writer, owner string, columnNames []string, rows []data.ReportRow) error {
+	var columns []ReportColumn
+	var gridColumns []string
+	for _, name := range columnNames {
+		column, ok := reportColumn(name)
+		if !ok || name == "Team" {
+			continue
+		}
+		columns = append(columns, column)
+		gridColumns = append(gridColumns, name)
+	}
+
+	stats := ComputeStats(owner, rows, 0)
+	report := htmlReport{
+		Organization: owner,
+		GeneratedAt:  time.Now().Format(time.DateTime),
+		Stats:        stats,
+		ByPermission: htmlBars(stats.ByPermission, stats.Grants),
+		ByVisibility: htmlBars(stats.ByVisibility, stats.Grants),
+		Columns:      gridColumns,
+	}
+
+	users := make(map[string]*htmlGroup)
+	repos := make(map[string]*htmlGroup)
+	for i := range rows {
+		row := &rows[i]
+		if row.Team != "" {
+			report.TeamMemberships = append(report.TeamMemberships, []string{row.Team, row.Username, GrantLevel(*row)})
+			continue
+		}
+		record := make([]string, len(columns))
+		for j, column := range columns {
+			record[j] = column.Get(row)
+		}
+		report.Rows = append(report.Rows, record)
+		addToGroup(users, row.Username, "", row.RepositoryName, row.AccessLevel)
+		addToGroup(repos, row.RepositoryName, row.Visibility, row.Username, row.AccessLevel)
+	}
+	report.Users = sortedGroups(users)
+	report.Repositories = sortedGroups(repos)
+
+	return htmlTemplates.ExecuteTemplate(w, "report", report)
+}
+
+func htmlBars(counts []data.StatsCount, total int) []htmlBar {
+	bars := make([]htmlBar, 0, len(counts))
+	for _, count := range counts {
+		bar := htmlBar{Name: count.Name, Count: count.Count}
+		if total > 0 {
+			bar.Percent = count.Count * 100 / total
+		}
+		bars = append(bars, bar)
+	}
+	return bars
+}
+
+// addToGroup counts a grant against a user or repository, keeping the
+// highest access level and a list of the grants.
+func addToGroup(groups map[string]*htmlGroup, name string, visibility string, grant string, accessLevel string) {
+	group, ok := groups[name]
+	if !ok {
+		group = &htmlGroup{Name: name, Visibility: visibility}
+		groups[name] = group
+	}
+	group.Count++
+	if PermissionRank(accessLevel) > PermissionRank(group.AccessLevel) {
+		group.AccessLevel = accessLevel
+	}
+	if len(group.Grants) > 0 {
+		group.Grants += ", "
+	}
+	group.Grants += grant + " (" + strings.ToUpper(accessLevel) + ")"
+}
+
+func sortedGroups(groups map[string]*htmlGroup) []htmlGroup {
+	sorted := make([]htmlGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// htmlTemplates share a page layout and the table sorting and filtering
+// script between the report layouts.
+var htmlTemplates = template.Must(template.New("html").Parse(`{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Repository collaborators in {{.}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.25em; border-bottom: 1px solid #d0d7de; padding-bottom: .3em; margin-top: 2em; }
+.meta { color: #656d76; }
+.totals { display: flex; gap: 1em; flex-wrap: wrap; }
+.total { border: 1px solid #d0d7de; border-radius: 6px; padding: .75em 1.25em; }
+.total b { display: block; font-size: 1.6em; }
+.charts { display: flex; gap: 3em; flex-wrap: wrap; }
+.chart { min-width: 320px; }
+.bar { display: flex; align-items: center; margin: .3em 0; }
+.bar span { width: 7em; }
+.track { flex: 1; margin-right: .5em; }
+.track div { background: #0969da; height: 1em; }
+input.filter { margin: .5em 0; padding: .3em; width: 20em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #d0d7de; padding: .3em .6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; cursor: pointer; user-select: none; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+tr:nth-child(even) td { background: #f6f8fa; }
+td.access { text-align: center; }
+</style>
+</head>
+<body>
+{{end}}{{define "footer"}}<script>
+document.querySelectorAll("input.filter").forEach(function (input) {
+  input.addEventListener("input", function () {
+    var term = input.value.toLowerCase();
+    document.querySelectorAll("#" + input.dataset.table + " tbody tr").forEach(function (row) {
+      row.style.display = row.textContent.toLowerCase().indexOf(term) >= 0 ? "" : "none";
+    });
+  });
+});
+document.querySelectorAll("th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table");
+    var index = Array.prototype.indexOf.call(th.parentNode.children, th);
+    var asc = !th.classList.contains("asc");
+    table.querySelectorAll("th").forEach(function (other) { other.classList.remove("asc", "desc"); });
+    th.classList.add(asc ? "asc" : "desc");
+    var numeric = th.dataset.type === "number";
+    var rows = Array.prototype.slice.call(table.tBodies[0].rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[index].textContent, y = b.cells[index].textContent;
+      var order = numeric ? Number(x) - Number(y) : x.localeCompare(y);
+      return asc ? order : -order;
+    });
+    rows.forEach(function (row) { table.tBodies[0].appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+{{end}}{{define "report"}}{{template "header" .Organization}}<h1>Repository collaborators in {{.Organization}}</h1>
+<p class="meta">Generated {{.GeneratedAt}}</p>
+
+<div class="totals">
+<div class="total"><b>{{.Stats.Guests}}</b>Guests</div>
+<div class="total"><b>{{.Stats.Repositories}}</b>Repositories with guests</div>
+<div class="total"><b>{{.Stats.Grants}}</b>Grants</div>
+<div class="total"><b>{{.Stats.WriteGrants}}</b>Grants with write or higher</div>
+</div>
+
+<div class="charts">
+<div class="chart">
+<h2>Grants by access level</h2>
+{{range .ByPermission}}<div class="bar"><span>{{.Name}}</span><div class="track"><div style="width: {{.Percent}}%"></div></div>{{.Count}}</div>
+{{end}}</div>
+<div class="chart">
+<h2>Grants by repository visibility</h2>
+{{range .ByVisibility}}<div class="bar"><span>{{.Name}}</span><div class="track"><div style="width: {{.Percent}}%"></div></div>{{.Count}}</div>
+{{end}}</div>
+</div>
+
+<h2>By User</h2>
+<input class="filter" type="search" placeholder="Filter users" data-table="users">
+<table id="users">
+<thead><tr><th>Username</th><th data-type="number">Repositories</th><th>Highest Access</th><th>Grants</th></tr></thead>
+<tbody>
+{{range .Users}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{.AccessLevel}}</td><td>{{.Grants}}</td></tr>
+{{end}}</tbody>
+</table>
+
+<h2>By Repository</h2>
+<input class="filter" type="search" placeholder="Filter repositories" data-table="repositories">
+<table id="repositories">
+<thead><tr><th>Repository</th><th>Visibility</th><th data-type="number">Guests</th><th>Highest Access</th><th>Grants</th></tr></thead>
+<tbody>
+{{range .Repositories}}<tr><td>{{.Name}}</td><td>{{.Visibility}}</td><td>{{.Count}}</td><td>{{.AccessLevel}}</td><td>{{.Grants}}</td></tr>
+{{end}}</tbody>
+</table>
+
+<h2>All Grants</h2>
+<input class="filter" type="search" placeholder="Filter grants" data-table="grants">
+<table id="grants">
+<thead><tr>{{range .Columns}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{if .TeamMemberships}}
+<h2>Team Memberships</h2>
+<input class="filter" type="search" placeholder="Filter team memberships" data-table="teams">
+<table id="teams">
+<thead><tr><th>Team</th><th>Username</th><th>Role</th></tr></thead>
+<tbody>
+{{range .TeamMemberships}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{end}}
+{{template "footer"}}{{end}}{{define "matrix"}}{{template "header" .Organization}}<h1>Repository collaborators in {{.Organization}}</h1>
+<p class="meta">Generated {{.GeneratedAt}}</p>
+
+<input class="filter" type="search" placeholder="Filter users" data-table="matrix">
+<table id="matrix">
+<thead><tr><th>Username</th>{{range .Repositories}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr><td>{{index . 0}}</td>{{range slice . 1}}<td class="access">{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{template "footer"}}{{end}}`))
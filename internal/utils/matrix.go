@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+// AccessMatrix pivots report rows into the access level of each guest, in
+// Rows, on each repository, in Repositories order.
+type AccessMatrix struct {
+	Organization string
+	GeneratedAt  string
+	Repositories []string
+	// Rows hold the username followed by the access level on each
+	// repository, empty where the user has no access.
+	Rows [][]string
+}
+
+// BuildAccessMatrix pivots the repository grants of a report, ignoring team
+// membership rows, with users and repositories sorted by name.
+func BuildAccessMatrix(owner string, rows []data.ReportRow) AccessMatrix {
+	access := make(map[string]map[string]string)
+	repoSet := make(map[string]bool)
+	for _, row := range rows {
+		if row.Team != "" {
+			continue
+		}
+		if access[row.Username] == nil {
+			access[row.Username] = make(map[string]string)
+		}
+		access[row.Username][row.RepositoryName] = row.AccessLevel
+		repoSet[row.RepositoryName] = true
+	}
+
+	matrix := AccessMatrix{Organization: owner, GeneratedAt: time.Now().Format(time.DateTime)}
+	for repo := range repoSet {
+		matrix.Repositories = append(matrix.Repositories, repo)
+	}
+	sort.Strings(matrix.Repositories)
+	users := make([]string, 0, len(access))
+	for user := range access {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return strings.ToLower(users[i]) < strings.ToLower(users[j]) })
+	for _, user := range users {
+		record := []string{user}
+		for _, repo := range matrix.Repositories {
+			record = append(record, access[user][repo])
+		}
+		matrix.Rows = append(matrix.Rows, record)
+	}
+	return matrix
+}
+
+func (m AccessMatrix) header() []string {
+	return append([]string{"Username"}, m.Repositories...)
+}
+
+func WriteMatrixCSV(w io.Writer, matrix AccessMatrix) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(matrix.header()); err != nil {
+		return err
+	}
+	if err := csvWriter.WriteAll(matrix.Rows); err != nil {
+		return err
+	}
+	return csvWriter.Error()
+}
+
+func WriteMatrixMarkdown(w io.Writer, matrix AccessMatrix) error {
+	return writeMarkdownTable(w, matrix.header(), matrix.Rows)
+}
+
+func WriteMatrixHTML(w io.Writer, matrix AccessMatrix) error {
+	return htmlTemplates.ExecuteTemplate(w, "matrix", matrix)
+}
+
+// WriteReportMarkdown writes the rows as a Markdown table with the named
+// columns.
+func WriteReportMarkdown(w io.Writer, columnNames []string, rows []data.ReportRow) error {
+	var columns []ReportColumn
+	for _, name := range columnNames {
+		column, ok := reportColumn(name)
+		if !ok {
+			return fmt.Errorf("unknown report column %s", name)
+		}
+		columns = append(columns, column)
+	}
+	records := make([][]string, 0, len(rows))
+	for i := range rows {
+		record := make([]string, len(columns))
+		for j, column := range columns {
+			record[j] = column.Get(&rows[i])
+		}
+		records = append(records, record)
+	}
+	return writeMarkdownTable(w, columnNames, records)
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ")
+
+func writeMarkdownTable(w io.Writer, header []string, records [][]string) error {
+	lines := []string{markdownRow(header), "|" + strings.Repeat(" --- |", len(header))}
+	for _, record := range records {
+		lines = append(lines, markdownRow(record))
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func markdownRow(record []string) string {
+	cells := make([]string, len(record))
+	for i, value := range record {
+		cells[i] = markdownEscaper.Replace(value)
+	}
+	return "| " + strings.Join(cells, " | ") + " |"
+}
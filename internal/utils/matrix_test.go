@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+func TestBuildAccessMatrix(t *testing.T) {
+	tests := []struct {
+		name             string
+		rows             []data.ReportRow
+		wantRepositories []string
+		wantRows         [][]string
+	}{
+		{
+			name: "empty report",
+		},
+		{
+			name: "users and repositories are sorted",
+			rows: []data.ReportRow{
+				{RepositoryName: "web", Username: "bob", AccessLevel: "WRITE"},
+				{RepositoryName: "api", Username: "Carol", AccessLevel: "READ"},
+				{RepositoryName: "api", Username: "alice", AccessLevel: "ADMIN"},
+			},
+			wantRepositories: []string{"api", "web"},
+			wantRows: [][]string{
+				{"alice", "ADMIN", ""},
+				{"bob", "", "WRITE"},
+				{"Carol", "READ", ""},
+			},
+		},
+		{
+			name: "team rows are ignored",
+			rows: []data.ReportRow{
+				{RepositoryName: "api", Username: "alice", AccessLevel: "TRIAGE"},
+				{Team: "eng", Username: "bob", TeamRole: "maintainer"},
+			},
+			wantRepositories: []string{"api"},
+			wantRows:         [][]string{{"alice", "TRIAGE"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BuildAccessMatrix("acme", tt.rows)
+			if got.Organization != "acme" {
+				t.Errorf("BuildAccessMatrix() organization = %q, want %q", got.Organization, "acme")
+			}
+			if !reflect.DeepEqual(got.Repositories, tt.wantRepositories) {
+				t.Errorf("BuildAccessMatrix() repositories = %q, want %q", got.Repositories, tt.wantRepositories)
+			}
+			if !reflect.DeepEqual(got.Rows, tt.wantRows) {
+				t.Errorf("BuildAccessMatrix() rows = %q, want %q", got.Rows, tt.wantRows)
+			}
+		})
+	}
+}
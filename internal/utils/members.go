@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cli/go-gh/pkg/api"
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+func (g *APIGetter) GetUser(username string) (*data.User, error) {
+	url := fmt.Sprintf("users/%s", username)
+	var user data.User
+	err := g.restClient.Get(url, &user)
+	return &user, err
+}
+
+// GetOrgGuestsWithout2FA returns the outside collaborators of the
+// organization without two-factor authentication enabled. Only organization
+// owners can list them.
+func (g *APIGetter) GetOrgGuestsWithout2FA(owner string) (map[string]bool, error) {
+	url := fmt.Sprintf("orgs/%s/outside_collaborators?filter=2fa_disabled&per_page=100", owner)
+	disabled := make(map[string]bool)
+	err := g.GetPaginated(url, func(body []byte) error {
+		var page []data.RepoCollaborators
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		for _, collaborator := range page {
+			disabled[collaborator.Login] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return disabled, nil
+}
+
+// RemoveOrgOutsideCollaborator removes a user from every repository in the
+// organization.
+func (g *APIGetter) RemoveOrgOutsideCollaborator(owner string, username string) error {
+	url := fmt.Sprintf("orgs/%s/outside_collaborators/%s", owner, username)
+	resp, err := g.restClient.Request("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// GetOrgMembership returns the organization membership of a user, or nil when
+// the user is neither a member nor invited to become one.
+func (g *APIGetter) GetOrgMembership(owner string, username string) (*data.OrgMembership, error) {
+	url := fmt.Sprintf("orgs/%s/memberships/%s", owner, username)
+	var membership data.OrgMembership
+	err := g.restClient.Get(url, &membership)
+	var httpErr api.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+func (g *APIGetter) CreateOrgInvitation(owner string, invitation data.OrgInvitation) error {
+	url := fmt.Sprintf("orgs/%s/invitations", owner)
+	body, err := json.Marshal(invitation)
+	if err != nil {
+		return err
+	}
+	resp, err := g.restClient.Request("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// GetAuthenticatedUser returns the user the token belongs to.
+func (g *APIGetter) GetAuthenticatedUser() (*data.User, error) {
+	var user data.User
+	err := g.restClient.Get("user", &user)
+	return &user, err
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/cli/go-gh/pkg/api"
+	"go.uber.org/zap"
+)
+
+const (
+	SplitByCodeowners = "codeowners"
+	SplitByTopic      = "topic"
+	SplitByTeam       = "team"
+
+	// UnownedReview names the workbook of repositories without an owner.
+	UnownedReview = "unowned"
+)
+
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+type repoContent struct {
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+type repoTopics struct {
+	Names []string `json:"names"`
+}
+
+type repoTeam struct {
+	Slug       string `json:"slug"`
+	Permission string `json:"permission"`
+}
+
+// GetRepoOwner determines the owner responsible for reviewing access to a
+// repository: the default owner in its CODEOWNERS file, its first topic or
+// its first team with admin access. Repositories without one have an empty
+// owner.
+func (g *APIGetter) GetRepoOwner(owner string, repo string, splitBy string) (string, error) {
+	var repoOwner string
+	var err error
+	switch splitBy {
+	case SplitByCodeowners:
+		repoOwner, err = g.getCodeowner(owner, repo)
+	case SplitByTopic:
+		var topics repoTopics
+		err = g.restClient.Get(fmt.Sprintf("repos/%s/%s/topics", owner, repo), &topics)
+		if len(topics.Names) > 0 {
+			repoOwner = topics.Names[0]
+		}
+	case SplitByTeam:
+		var teams []repoTeam
+		err = g.restClient.Get(fmt.Sprintf("repos/%s/%s/teams?per_page=100", owner, repo), &teams)
+		for _, team := range teams {
+			if team.Permission == "admin" {
+				repoOwner = team.Slug
+				break
+			}
+		}
+	default:
+		return "", fmt.Errorf("unsupported split %q, must be one of codeowners, topic or team", splitBy)
+	}
+	if err != nil {
+		return "", err
+	}
+	return repoOwner, nil
+}
+
+func (g *APIGetter) getCodeowner(owner string, repo string) (string, error) {
+	for _, path := range codeownersPaths {
+		var content repoContent
+		err := g.restClient.Get(fmt.Sprintf("repos/%s/%s/contents/%s", owner, repo, path), &content)
+		var httpErr api.HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(content.Content, "\n", ""))
+		if err != nil {
+			return "", err
+		}
+		zap.S().Debugf("Read CODEOWNERS of repo %s from %s", repo, path)
+		return DefaultCodeowner(string(decoded)), nil
+	}
+	return "", nil
+}
+
+// DefaultCodeowner returns the first owner of the last rule matching every
+// file in a CODEOWNERS file, falling back to the first owner of the first
+// rule.
+func DefaultCodeowner(codeowners string) string {
+	var defaultOwner, firstOwner string
+	scanner := bufio.NewScanner(strings.NewReader(codeowners))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ruleOwner := strings.TrimPrefix(fields[1], "@")
+		if firstOwner == "" {
+			firstOwner = ruleOwner
+		}
+		if fields[0] == "*" || fields[0] == "/*" || fields[0] == "/" {
+			defaultOwner = ruleOwner
+		}
+	}
+	if defaultOwner != "" {
+		return defaultOwner
+	}
+	return firstOwner
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ReviewFileNames assigns each owner, in order, a workbook file name, with
+// repositories without an owner written to the UnownedReview workbook.
+// Replacing the characters that are unsafe in file names can give different
+// owners the same name, so later owners get a numeric suffix, ignoring case
+// for case-insensitive file systems.
+func ReviewFileNames(repoOwners []string) map[string]string {
+	fileNames := make(map[string]string, len(repoOwners))
+	used := make(map[string]bool, len(repoOwners))
+	for _, repoOwner := range repoOwners {
+		label := repoOwner
+		if label == "" {
+			label = UnownedReview
+		}
+		base := unsafeFileChars.ReplaceAllString(label, "_")
+		fileName := fmt.Sprintf("AccessReview-%s.csv", base)
+		for i := 2; used[strings.ToLower(fileName)]; i++ {
+			fileName = fmt.Sprintf("AccessReview-%s-%d.csv", base, i)
+		}
+		used[strings.ToLower(fileName)] = true
+		fileNames[repoOwner] = fileName
+	}
+	return fileNames
+}
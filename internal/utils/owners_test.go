@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDefaultCodeowner(t *testing.T) {
+	tests := []struct {
+		name       string
+		codeowners string
+		want       string
+	}{
+		{
+			name:       "default rule",
+			codeowners: "*.go @org/go-team\n* @org/platform @alice\n",
+			want:       "org/platform",
+		},
+		{
+			name:       "last default rule wins",
+			codeowners: "* @org/first\n/docs/ @org/docs\n/* @org/second\n",
+			want:       "org/second",
+		},
+		{
+			name:       "falls back to the first rule",
+			codeowners: "/docs/ @org/docs\n*.go @org/go-team\n",
+			want:       "org/docs",
+		},
+		{
+			name:       "comments and rules without owners are skipped",
+			codeowners: "# * @org/commented\n/vendor/\n*.md @org/writers # docs\n",
+			want:       "org/writers",
+		},
+		{
+			name:       "email owner",
+			codeowners: "* alice@example.com\n",
+			want:       "alice@example.com",
+		},
+		{
+			name:       "no owners",
+			codeowners: "# nothing here\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultCodeowner(tt.codeowners); got != tt.want {
+				t.Errorf("DefaultCodeowner() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReviewFileNames(t *testing.T) {
+	tests := []struct {
+		name       string
+		repoOwners []string
+		want       map[string]string
+	}{
+		{
+			name:       "unsafe characters are replaced",
+			repoOwners: []string{"org/platform", "alice@example.com"},
+			want: map[string]string{
+				"org/platform":      "AccessReview-org_platform.csv",
+				"alice@example.com": "AccessReview-alice_example.com.csv",
+			},
+		},
+		{
+			name:       "repositories without an owner",
+			repoOwners: []string{""},
+			want:       map[string]string{"": "AccessReview-unowned.csv"},
+		},
+		{
+			name:       "colliding names get a suffix",
+			repoOwners: []string{"org/web", "org_web", "org web"},
+			want: map[string]string{
+				"org/web": "AccessReview-org_web.csv",
+				"org_web": "AccessReview-org_web-2.csv",
+				"org web": "AccessReview-org_web-3.csv",
+			},
+		},
+		{
+			name:       "names differing only in case collide",
+			repoOwners: []string{"Platform", "platform"},
+			want: map[string]string{
+				"Platform": "AccessReview-Platform.csv",
+				"platform": "AccessReview-platform-2.csv",
+			},
+		},
+		{
+			name:       "an owner named unowned does not share the unowned workbook",
+			repoOwners: []string{"", "unowned"},
+			want: map[string]string{
+				"":        "AccessReview-unowned.csv",
+				"unowned": "AccessReview-unowned-2.csv",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReviewFileNames(tt.repoOwners); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReviewFileNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
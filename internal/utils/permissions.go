@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+// AccessSources splits the permission sources of a collaborator edge into
+// direct repository grants, team grants and organization level grants
+// (base role or owner access), formatted for report output.
+func AccessSources(edge data.Edge) (direct string, teams string, org string) {
+	var teamSources []string
+	var orgSources []string
+	for _, source := range edge.PermissionSources {
+		switch source.Source.Typename {
+		case "Repository":
+			direct = source.Permission
+		case "Team":
+			teamSources = append(teamSources, fmt.Sprintf("%s:%s", source.Source.Team.Slug, source.Permission))
+		case "Organization":
+			orgSources = append(orgSources, fmt.Sprintf("%s:%s", source.Source.Organization.Login, source.Permission))
+		}
+	}
+	return direct, strings.Join(teamSources, ";"), strings.Join(orgSources, ";")
+}
+
+// DescribeAccessSources names where the access of a collaborator edge comes
+// from, such as "team eng:WRITE, organization my-org:READ".
+func DescribeAccessSources(edge data.Edge) string {
+	direct, teams, org := AccessSources(edge)
+	var sources []string
+	if direct != "" {
+		sources = append(sources, "direct "+direct)
+	}
+	if teams != "" {
+		sources = append(sources, "team "+teams)
+	}
+	if org != "" {
+		sources = append(sources, "organization "+org)
+	}
+	return strings.Join(sources, ", ")
+}
+
+// CollaboratorEdge returns the collaborator edge of a repository belonging to
+// the user. The collaborators query matches on partial logins, so the edge is
+// compared against the full login.
+func CollaboratorEdge(repo data.RepoInfo, user string) (data.Edge, bool) {
+	for _, edge := range repo.Collaborators.Edges {
+		if strings.EqualFold(edge.Node.Login, user) {
+			return edge, true
+		}
+	}
+	return data.Edge{}, false
+}
+
+// IsDirectGrant reports whether the edge includes access granted directly on
+// the repository.
+func IsDirectGrant(edge data.Edge) bool {
+	if len(edge.PermissionSources) == 0 {
+		return true
+	}
+	for _, source := range edge.PermissionSources {
+		if source.Source.Typename == "Repository" {
+			return true
+		}
+	}
+	return false
+}
+
+var permissionRanks = map[string]int{
+	"read":     1,
+	"pull":     1,
+	"triage":   2,
+	"write":    3,
+	"push":     3,
+	"maintain": 4,
+	"admin":    5,
+}
+
+// PermissionRank orders repository permissions from READ (1) to ADMIN (5),
+// accepting both GraphQL and REST permission names. Unknown permissions,
+// such as custom repository roles, rank as 0.
+func PermissionRank(permission string) int {
+	return permissionRanks[strings.ToLower(permission)]
+}
+
+// RESTPermission converts a GraphQL permission name such as WRITE to the
+// value expected by the REST API. Other values are passed through unchanged.
+func RESTPermission(permission string) string {
+	switch strings.ToLower(permission) {
+	case "read":
+		return "pull"
+	case "write":
+		return "push"
+	case "triage", "maintain", "admin", "pull", "push":
+		return strings.ToLower(permission)
+	}
+	return permission
+}
+
+// IsRedundantDirectGrant reports whether the access granted directly on a
+// repository is matched or exceeded by a team or organization grant.
+func IsRedundantDirectGrant(edge data.Edge) bool {
+	direct := ""
+	for _, source := range edge.PermissionSources {
+		if source.Source.Typename == "Repository" {
+			direct = source.Permission
+		}
+	}
+	if direct == "" {
+		return false
+	}
+	for _, source := range edge.PermissionSources {
+		if source.Source.Typename != "Repository" && PermissionRank(source.Permission) >= PermissionRank(direct) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	RemediationRemove    = "remove"
+	RemediationDowngrade = "downgrade"
+)
+
+// LoadPolicy reads and validates a YAML policy file.
+func LoadPolicy(fileName string) (*data.Policy, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var policy data.Policy
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", fileName, err)
+	}
+	if len(policy.Rules) == 0 {
+		return nil, fmt.Errorf("policy %s does not define any rules", fileName)
+	}
+	for i, rule := range policy.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("rule %d in policy %s has no name", i+1, fileName)
+		}
+		if len(rule.DenyPermissions) == 0 && rule.MaxPermission == "" && len(rule.DenyVisibility) == 0 && rule.MaxReposPerUser == 0 {
+			return nil, fmt.Errorf("rule %s in policy %s has no restrictions", rule.Name, fileName)
+		}
+		if rule.MaxPermission != "" && PermissionRank(rule.MaxPermission) == 0 {
+			return nil, fmt.Errorf("rule %s in policy %s has unknown max_permission %s", rule.Name, fileName, rule.MaxPermission)
+		}
+		if rule.DowngradeTo != "" && PermissionRank(rule.DowngradeTo) == 0 {
+			return nil, fmt.Errorf("rule %s in policy %s has unknown downgrade_to %s", rule.Name, fileName, rule.DowngradeTo)
+		}
+		switch rule.Remediation {
+		case "", RemediationRemove:
+		case RemediationDowngrade:
+			if rule.DowngradeTo == "" && rule.MaxPermission == "" {
+				return nil, fmt.Errorf("rule %s in policy %s downgrades access but has no downgrade_to or max_permission", rule.Name, fileName)
+			}
+		default:
+			return nil, fmt.Errorf("rule %s in policy %s has unknown remediation %s, must be remove or downgrade", rule.Name, fileName, rule.Remediation)
+		}
+	}
+	return &policy, nil
+}
+
+// EvaluatePolicy returns the violations of every policy rule by the report
+// rows. Team membership rows are not evaluated.
+func EvaluatePolicy(policy *data.Policy, rows []data.ReportRow) []data.PolicyViolation {
+	var violations []data.PolicyViolation
+	for _, rule := range policy.Rules {
+		reposPerUser := make(map[string][]data.ReportRow)
+		for _, row := range rows {
+			if row.Team != "" || !RuleSelects(rule, row) {
+				continue
+			}
+			reposPerUser[row.Username] = append(reposPerUser[row.Username], row)
+
+			if containsFold(rule.DenyPermissions, row.AccessLevel) {
+				v := violation(rule, row, fmt.Sprintf("%s access is not allowed", row.AccessLevel))
+				v.Remediation, v.NewAccessLevel = permissionRemediation(rule, row)
+				violations = append(violations, v)
+			} else if rule.MaxPermission != "" && PermissionRank(row.AccessLevel) > PermissionRank(rule.MaxPermission) {
+				v := violation(rule, row, fmt.Sprintf("%s access exceeds the maximum of %s", row.AccessLevel, strings.ToUpper(rule.MaxPermission)))
+				v.Remediation, v.NewAccessLevel = permissionRemediation(rule, row)
+				violations = append(violations, v)
+			}
+			if containsFold(rule.DenyVisibility, row.Visibility) {
+				v := violation(rule, row, fmt.Sprintf("access to %s repositories is not allowed", row.Visibility))
+				v.Remediation = RemediationRemove
+				violations = append(violations, v)
+			}
+		}
+		if rule.MaxReposPerUser == 0 {
+			continue
+		}
+		for username, userRows := range reposPerUser {
+			if len(userRows) > rule.MaxReposPerUser {
+				violations = append(violations, data.PolicyViolation{
+					Rule:     rule.Name,
+					Username: username,
+					Message:  fmt.Sprintf("access to %d repositories exceeds the maximum of %d", len(userRows), rule.MaxReposPerUser),
+				})
+			}
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Username != violations[j].Username {
+			return violations[i].Username < violations[j].Username
+		}
+		return violations[i].RepositoryName < violations[j].RepositoryName
+	})
+	return violations
+}
+
+// RuleSelects reports whether the rule's user and repository selectors
+// match the row.
+func RuleSelects(rule data.PolicyRule, row data.ReportRow) bool {
+	if len(rule.Users) > 0 && !MatchesAny(rule.Users, row.Username) {
+		return false
+	}
+	if MatchesAny(rule.ExcludeUsers, row.Username) {
+		return false
+	}
+	if len(rule.Repositories) > 0 && !MatchesAny(rule.Repositories, row.RepositoryName) {
+		return false
+	}
+	return true
+}
+
+// MatchesAny reports whether the value matches any of the case-insensitive
+// glob patterns.
+func MatchesAny(patterns []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// permissionRemediation decides how a grant with too much access is fixed,
+// downgrading to downgrade_to or max_permission unless the rule removes
+// access. Without a lower access level to downgrade to, access is removed.
+func permissionRemediation(rule data.PolicyRule, row data.ReportRow) (string, string) {
+	target := rule.DowngradeTo
+	if target == "" {
+		target = rule.MaxPermission
+	}
+	if rule.Remediation == RemediationRemove || target == "" || PermissionRank(target) >= PermissionRank(row.AccessLevel) {
+		return RemediationRemove, ""
+	}
+	return RemediationDowngrade, strings.ToUpper(target)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func violation(rule data.PolicyRule, row data.ReportRow, message string) data.PolicyViolation {
+	return data.PolicyViolation{
+		Rule:           rule.Name,
+		RepositoryName: row.RepositoryName,
+		RepositoryID:   row.RepositoryID,
+		Visibility:     row.Visibility,
+		Username:       row.Username,
+		AccessLevel:    row.AccessLevel,
+		Message:        message,
+	}
+}
+
+// LoadAllowlist reads a CSV file of RepositoryName and Username glob
+// patterns of grants that must never be remediated. The header is required,
+// as a first entry mistaken for a header would be remediated.
+func LoadAllowlist(fileName string) ([]data.ImportedRepoCollab, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "repositoryname" || name == "username" {
+			columns[name] = i
+		}
+	}
+	if len(columns) < 2 {
+		return nil, fmt.Errorf("allowlist %s must start with a header naming its RepositoryName and Username columns", fileName)
+	}
+	var allowlist []data.ImportedRepoCollab
+	for i, record := range records[1:] {
+		entry := data.ImportedRepoCollab{
+			RepositoryName: columnValue(record, columns, "repositoryname"),
+			Username:       columnValue(record, columns, "username"),
+		}
+		// an entry with both fields empty would match every grant
+		if entry.RepositoryName == "" && entry.Username == "" {
+			return nil, fmt.Errorf("allowlist %s has entry %d without a RepositoryName or Username, use * to match every repository or user", fileName, i+1)
+		}
+		allowlist = append(allowlist, entry)
+	}
+	return allowlist, nil
+}
+
+// IsAllowlisted reports whether an allowlist entry matches the repository
+// and user. Empty fields in an entry match everything.
+func IsAllowlisted(allowlist []data.ImportedRepoCollab, repo string, username string) bool {
+	for _, entry := range allowlist {
+		repoMatch := entry.RepositoryName == "" || MatchesAny([]string{entry.RepositoryName}, repo)
+		userMatch := entry.Username == "" || MatchesAny([]string{entry.Username}, username)
+		if repoMatch && userMatch {
+			return true
+		}
+	}
+	return false
+}
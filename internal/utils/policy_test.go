@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+func TestEvaluatePolicy(t *testing.T) {
+	rows := []data.ReportRow{
+		{RepositoryName: "api", Visibility: "private", Username: "alice", AccessLevel: "ADMIN"},
+		{RepositoryName: "docs", Visibility: "public", Username: "alice", AccessLevel: "WRITE"},
+		{RepositoryName: "infra", Visibility: "internal", Username: "bob", AccessLevel: "MAINTAIN"},
+		{RepositoryName: "api", Visibility: "private", Username: "bot-deploy", AccessLevel: "ADMIN"},
+		{Team: "eng", Username: "alice", TeamRole: "maintainer"},
+	}
+	tests := []struct {
+		name string
+		rule data.PolicyRule
+		want []string
+	}{
+		{
+			name: "denied permission",
+			rule: data.PolicyRule{Name: "no-admin", DenyPermissions: []string{"admin"}},
+			want: []string{
+				"alice/api: ADMIN access is not allowed",
+				"bot-deploy/api: ADMIN access is not allowed",
+			},
+		},
+		{
+			name: "excluded users are not evaluated",
+			rule: data.PolicyRule{Name: "no-admin", DenyPermissions: []string{"ADMIN"}, ExcludeUsers: []string{"BOT-*"}},
+			want: []string{"alice/api: ADMIN access is not allowed"},
+		},
+		{
+			name: "maximum permission",
+			rule: data.PolicyRule{Name: "max-write", MaxPermission: "write"},
+			want: []string{
+				"alice/api: ADMIN access exceeds the maximum of WRITE",
+				"bob/infra: MAINTAIN access exceeds the maximum of WRITE",
+				"bot-deploy/api: ADMIN access exceeds the maximum of WRITE",
+			},
+		},
+		{
+			name: "denied visibility on selected repositories",
+			rule: data.PolicyRule{Name: "no-internal", Repositories: []string{"infra*"}, DenyVisibility: []string{"Internal"}},
+			want: []string{"bob/infra: access to internal repositories is not allowed"},
+		},
+		{
+			name: "maximum repositories per user",
+			rule: data.PolicyRule{Name: "one-repo", Users: []string{"alice", "bob"}, MaxReposPerUser: 1},
+			want: []string{"alice/: access to 2 repositories exceeds the maximum of 1"},
+		},
+		{
+			name: "no violations",
+			rule: data.PolicyRule{Name: "no-triage", DenyPermissions: []string{"TRIAGE"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &data.Policy{Rules: []data.PolicyRule{tt.rule}}
+			var got []string
+			for _, v := range EvaluatePolicy(policy, rows) {
+				if v.Rule != tt.rule.Name {
+					t.Errorf("violation of rule %s, want %s", v.Rule, tt.rule.Name)
+				}
+				got = append(got, v.Username+"/"+v.RepositoryName+": "+v.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EvaluatePolicy() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicyRemediation(t *testing.T) {
+	row := data.ReportRow{RepositoryName: "api", Visibility: "public", Username: "alice", AccessLevel: "ADMIN"}
+	tests := []struct {
+		name               string
+		rule               data.PolicyRule
+		wantRemediation    string
+		wantNewAccessLevel string
+	}{
+		{
+			name:            "denied permission is removed by default",
+			rule:            data.PolicyRule{Name: "no-admin", DenyPermissions: []string{"ADMIN"}},
+			wantRemediation: RemediationRemove,
+		},
+		{
+			name:               "downgraded to downgrade_to",
+			rule:               data.PolicyRule{Name: "no-admin", DenyPermissions: []string{"ADMIN"}, Remediation: RemediationDowngrade, DowngradeTo: "read"},
+			wantRemediation:    RemediationDowngrade,
+			wantNewAccessLevel: "READ",
+		},
+		{
+			name:               "downgraded to max_permission",
+			rule:               data.PolicyRule{Name: "max-write", MaxPermission: "write", Remediation: RemediationDowngrade},
+			wantRemediation:    RemediationDowngrade,
+			wantNewAccessLevel: "WRITE",
+		},
+		{
+			name:            "removed when downgrade_to is not lower",
+			rule:            data.PolicyRule{Name: "no-admin", DenyPermissions: []string{"ADMIN"}, Remediation: RemediationDowngrade, DowngradeTo: "admin"},
+			wantRemediation: RemediationRemove,
+		},
+		{
+			name:            "denied visibility is always removed",
+			rule:            data.PolicyRule{Name: "no-public", DenyVisibility: []string{"public"}, Remediation: RemediationDowngrade, DowngradeTo: "read"},
+			wantRemediation: RemediationRemove,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &data.Policy{Rules: []data.PolicyRule{tt.rule}}
+			violations := EvaluatePolicy(policy, []data.ReportRow{row})
+			if len(violations) != 1 {
+				t.Fatalf("EvaluatePolicy() returned %d violations, want 1", len(violations))
+			}
+			if violations[0].Remediation != tt.wantRemediation || violations[0].NewAccessLevel != tt.wantNewAccessLevel {
+				t.Errorf("EvaluatePolicy() remediation = %q %q, want %q %q", violations[0].Remediation, violations[0].NewAccessLevel, tt.wantRemediation, tt.wantNewAccessLevel)
+			}
+		})
+	}
+}
+
+func TestLoadAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []data.ImportedRepoCollab
+		wantErr bool
+	}{
+		{
+			name:    "header in any order",
+			content: "Username,RepositoryName\nalice,api\nbot-*,*\n",
+			want: []data.ImportedRepoCollab{
+				{RepositoryName: "api", Username: "alice"},
+				{RepositoryName: "*", Username: "bot-*"},
+			},
+		},
+		{
+			name:    "extra columns are ignored",
+			content: "RepositoryName,RepositoryID,Username,AccessLevel\napi,1, alice ,WRITE\n",
+			want:    []data.ImportedRepoCollab{{RepositoryName: "api", Username: "alice"}},
+		},
+		{
+			name:    "empty field matches everything",
+			content: "RepositoryName,Username\n,alice\n",
+			want:    []data.ImportedRepoCollab{{Username: "alice"}},
+		},
+		{
+			name:    "header only",
+			content: "RepositoryName,Username\n",
+		},
+		{
+			name: "empty file",
+		},
+		{
+			name:    "missing header",
+			content: "api,alice\ndocs,bob\n",
+			wantErr: true,
+		},
+		{
+			name:    "header without a Username column",
+			content: "RepositoryName,AccessLevel\napi,WRITE\n",
+			wantErr: true,
+		},
+		{
+			name:    "entry without a repository or user",
+			content: "RepositoryName,Username\n,\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "allowlist.csv")
+			if err := os.WriteFile(fileName, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadAllowlist(fileName)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("LoadAllowlist() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadAllowlist() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadAllowlist() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// ProgressRedrawInterval is how often the status line is redrawn on a
+	// terminal.
+	ProgressRedrawInterval = 200 * time.Millisecond
+	// ProgressLineInterval is how often a progress line is printed when
+	// stderr is not a terminal.
+	ProgressLineInterval = 15 * time.Second
+)
+
+// Progress reports how far a long running command has got on stderr. On a
+// terminal it redraws a single status line in place, otherwise it prints a
+// plain line every ProgressLineInterval so logs of CI runs show progress.
+//
+// A command runs through phases, such as gathering access and then removing
+// it, each counting steps towards a total. API calls and the remaining rate
+// limit are counted by the client Transport. A nil Progress reports nothing,
+// so it is optional everywhere.
+type Progress struct {
+	mu         sync.Mutex
+	out        io.Writer
+	tty        bool
+	width      int
+	phase      string
+	unit       string
+	done       int
+	total      int
+	repos      int
+	apiCalls   int
+	rateLimit  map[string]string
+	phaseStart time.Time
+	lastDraw   time.Time
+}
+
+// NewProgress reports progress to out, redrawing a status line when it is a
+// terminal unless plain lines are asked for, such as when debug logging is
+// also written to it.
+func NewProgress(out *os.File, plain bool) *Progress {
+	p := &Progress{
+		out:       out,
+		tty:       !plain && term.IsTerminal(int(out.Fd())),
+		rateLimit: make(map[string]string),
+	}
+	if p.tty {
+		p.width, _, _ = term.GetSize(int(out.Fd()))
+	}
+	return p
+}
+
+// Transport wraps the RoundTripper of an API client to count its calls and
+// record the remaining rate limit of each API resource.
+func (p *Progress) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if p == nil {
+		return base
+	}
+	return &progressTransport{progress: p, base: base}
+}
+
+type progressTransport struct {
+	progress *Progress
+	base     http.RoundTripper
+}
+
+func (t *progressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	t.progress.apiCall(resp)
+	return resp, err
+}
+
+func (p *Progress) apiCall(resp *http.Response) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.apiCalls++
+	if resp != nil {
+		if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+			resource := resp.Header.Get("X-RateLimit-Resource")
+			if resource == "" {
+				resource = "core"
+			}
+			p.rateLimit[resource] = remaining
+		}
+	}
+	p.draw(false)
+}
+
+// Start begins a phase of total steps counted in unit, finishing the
+// previous phase.
+func (p *Progress) Start(phase string, unit string, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finish()
+	p.phase, p.unit, p.done, p.total, p.repos = phase, unit, 0, total, 0
+	p.phaseStart = time.Now()
+	p.draw(true)
+}
+
+// Step counts a step of the current phase as done.
+func (p *Progress) Step() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.draw(false)
+}
+
+// AddRepos counts repositories paged through in the current phase.
+func (p *Progress) AddRepos(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.repos += n
+	p.draw(false)
+}
+
+// Done finishes the current phase, leaving its final status on stderr. It
+// must be called before writing anything else to stderr, such as a prompt.
+func (p *Progress) Done() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.finish()
+}
+
+func (p *Progress) finish() {
+	if p.phase == "" {
+		return
+	}
+	if p.tty {
+		fmt.Fprintf(p.out, "\r\033[K%s\n", p.status(true))
+	} else {
+		fmt.Fprintln(p.out, p.status(true))
+	}
+	p.phase = ""
+}
+
+// draw writes the status of the current phase, at most once per interval
+// unless forced.
+func (p *Progress) draw(force bool) {
+	if p.phase == "" {
+		return
+	}
+	interval := ProgressLineInterval
+	if p.tty {
+		interval = ProgressRedrawInterval
+	}
+	now := time.Now()
+	if !force && now.Sub(p.lastDraw) < interval {
+		return
+	}
+	p.lastDraw = now
+	if p.tty {
+		// a status line wrapping onto the next line could not be redrawn
+		status := p.status(false)
+		if p.width > 0 && len(status) >= p.width {
+			status = status[:p.width-1]
+		}
+		fmt.Fprintf(p.out, "\r\033[K%s", status)
+	} else {
+		fmt.Fprintln(p.out, p.status(false))
+	}
+}
+
+func (p *Progress) status(final bool) string {
+	var parts []string
+	if p.total > 0 {
+		parts = append(parts, fmt.Sprintf("%d/%d %s", p.done, p.total, p.unit))
+	} else if p.done > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", p.done, p.unit))
+	}
+	// the ETA comes first so it survives truncating the status line
+	elapsed := time.Since(p.phaseStart)
+	switch {
+	case final:
+		parts = append(parts, "took "+elapsed.Round(time.Second).String())
+	case p.done > 0 && p.total > p.done:
+		eta := elapsed / time.Duration(p.done) * time.Duration(p.total-p.done)
+		parts = append(parts, "ETA "+eta.Round(time.Second).String())
+	}
+	if p.repos > 0 {
+		parts = append(parts, fmt.Sprintf("%d repos paged", p.repos))
+	}
+	parts = append(parts, fmt.Sprintf("%d API calls", p.apiCalls))
+	if len(p.rateLimit) > 0 {
+		var resources []string
+		for resource := range p.rateLimit {
+			resources = append(resources, resource)
+		}
+		sort.Strings(resources)
+		var limits []string
+		for _, resource := range resources {
+			limits = append(limits, fmt.Sprintf("%s %s", resource, p.rateLimit[resource]))
+		}
+		parts = append(parts, "rate limit remaining "+strings.Join(limits, ", "))
+	}
+	return p.phase + ": " + strings.Join(parts, ", ")
+}
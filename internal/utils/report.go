@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+type ReportColumn struct {
+	Name string
+	Get  func(row *data.ReportRow) string
+	Set  func(row *data.ReportRow, value string)
+}
+
+// ReportColumns lists every column a report can contain, in output order.
+var ReportColumns = []ReportColumn{
+	{"RepositoryName", func(r *data.ReportRow) string { return r.RepositoryName }, func(r *data.ReportRow, v string) { r.RepositoryName = v }},
+	{"RepositoryID", func(r *data.ReportRow) string { return r.RepositoryID }, func(r *data.ReportRow, v string) { r.RepositoryID = v }},
+	{"Visibility", func(r *data.ReportRow) string { return r.Visibility }, func(r *data.ReportRow, v string) { r.Visibility = v }},
+	{"Username", func(r *data.ReportRow) string { return r.Username }, func(r *data.ReportRow, v string) { r.Username = v }},
+	{"AccessLevel", func(r *data.ReportRow) string { return r.AccessLevel }, func(r *data.ReportRow, v string) { r.AccessLevel = v }},
+	{"DirectAccess", func(r *data.ReportRow) string { return r.DirectAccess }, func(r *data.ReportRow, v string) { r.DirectAccess = v }},
+	{"TeamAccess", func(r *data.ReportRow) string { return r.TeamAccess }, func(r *data.ReportRow, v string) { r.TeamAccess = v }},
+	{"OrganizationAccess", func(r *data.ReportRow) string { return r.OrganizationAccess }, func(r *data.ReportRow, v string) { r.OrganizationAccess = v }},
+	{"Team", func(r *data.ReportRow) string { return r.Team }, func(r *data.ReportRow, v string) { r.Team = v }},
+	{"TeamRole", func(r *data.ReportRow) string { return r.TeamRole }, func(r *data.ReportRow, v string) { r.TeamRole = v }},
+	{"IsArchived", func(r *data.ReportRow) string { return r.IsArchived }, func(r *data.ReportRow, v string) { r.IsArchived = v }},
+	{"IsFork", func(r *data.ReportRow) string { return r.IsFork }, func(r *data.ReportRow, v string) { r.IsFork = v }},
+	{"PushedAt", func(r *data.ReportRow) string { return r.PushedAt }, func(r *data.ReportRow, v string) { r.PushedAt = v }},
+	{"DefaultBranch", func(r *data.ReportRow) string { return r.DefaultBranch }, func(r *data.ReportRow, v string) { r.DefaultBranch = v }},
+	{"Topics", func(r *data.ReportRow) string { return r.Topics }, func(r *data.ReportRow, v string) { r.Topics = v }},
+	{"Name", func(r *data.ReportRow) string { return r.Name }, func(r *data.ReportRow, v string) { r.Name = v }},
+	{"Company", func(r *data.ReportRow) string { return r.Company }, func(r *data.ReportRow, v string) { r.Company = v }},
+	{"Email", func(r *data.ReportRow) string { return r.Email }, func(r *data.ReportRow, v string) { r.Email = v }},
+	{"CreatedAt", func(r *data.ReportRow) string { return r.CreatedAt }, func(r *data.ReportRow, v string) { r.CreatedAt = v }},
+	{"TwoFactorEnabled", func(r *data.ReportRow) string { return r.TwoFactorEnabled }, func(r *data.ReportRow, v string) { r.TwoFactorEnabled = v }},
+}
+
+// MetadataColumns are the repository and user columns that can be added to a
+// list report, keyed by their lower case name.
+var MetadataColumns = map[string]string{
+	"isarchived":       "IsArchived",
+	"isfork":           "IsFork",
+	"pushedat":         "PushedAt",
+	"defaultbranch":    "DefaultBranch",
+	"topics":           "Topics",
+	"name":             "Name",
+	"company":          "Company",
+	"email":            "Email",
+	"createdat":        "CreatedAt",
+	"twofactorenabled": "TwoFactorEnabled",
+	"2fa":              "TwoFactorEnabled",
+}
+
+var userMetadataColumns = map[string]bool{"Name": true, "Company": true, "Email": true, "CreatedAt": true}
+
+// ParseMetadataColumns resolves the names given to --columns to report
+// column names, in the order given.
+func ParseMetadataColumns(names []string) ([]string, error) {
+	var columns []string
+	for _, name := range names {
+		column, ok := MetadataColumns[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, must be one of isArchived, isFork, pushedAt, defaultBranch, topics, name, company, email, createdAt or 2fa", name)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// BaseReportColumns are the columns always written to a list report.
+var BaseReportColumns = []string{"RepositoryName", "RepositoryID", "Visibility", "Username", "AccessLevel"}
+
+func reportColumn(name string) (ReportColumn, bool) {
+	for _, column := range ReportColumns {
+		if strings.EqualFold(column.Name, name) {
+			return column, true
+		}
+	}
+	return ReportColumn{}, false
+}
+
+// ReadReport reads a list report in either the CSV or JSON format, detecting
+// JSON from a leading '['.
+func ReadReport(r io.Reader) ([]data.ReportRow, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		var rows []data.ReportRow
+		err = json.Unmarshal(trimmed, &rows)
+		return rows, err
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	columns := make([]*ReportColumn, len(records[0]))
+	known := false
+	for i, name := range records[0] {
+		if column, ok := reportColumn(strings.TrimSpace(name)); ok {
+			columns[i] = &column
+			known = true
+		}
+	}
+	if !known {
+		return nil, fmt.Errorf("report header does not contain any known columns: %s", strings.Join(records[0], ","))
+	}
+	rows := make([]data.ReportRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		var row data.ReportRow
+		for i, value := range record {
+			if i < len(columns) && columns[i] != nil {
+				columns[i].Set(&row, strings.TrimSpace(value))
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// WriteReportCSV writes the rows with the named columns, header first.
+func WriteReportCSV(w io.Writer, columnNames []string, rows []data.ReportRow) error {
+	var columns []ReportColumn
+	for _, name := range columnNames {
+		column, ok := reportColumn(name)
+		if !ok {
+			return fmt.Errorf("unknown report column %s", name)
+		}
+		columns = append(columns, column)
+	}
+
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(columnNames); err != nil {
+		return err
+	}
+	for i := range rows {
+		record := make([]string, len(columns))
+		for j, column := range columns {
+			record[j] = column.Get(&rows[i])
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+func WriteReportJSON(w io.Writer, rows []data.ReportRow) error {
+	if rows == nil {
+		rows = []data.ReportRow{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+const (
+	DecisionKeep      = "keep"
+	DecisionRevoke    = "revoke"
+	DecisionDowngrade = "downgrade"
+)
+
+// ReviewManifestFile is the name of the manifest written alongside split
+// review workbooks.
+const ReviewManifestFile = "AccessReviewManifest.json"
+
+// ReviewColumns are the columns of an access review workbook.
+var ReviewColumns = []string{"RepositoryName", "RepositoryID", "Visibility", "Username", "AccessLevel", "Owner", "Reviewer", "Decision", "NewAccessLevel", "Comment"}
+
+// WriteReviewWorkbook writes a review row for every grant in the report, with
+// the Reviewer, Decision, NewAccessLevel and Comment columns left for the
+// reviewer.
+func WriteReviewWorkbook(w io.Writer, rows []data.ReportRow, repoOwner string) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(ReviewColumns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.Team != "" {
+			continue
+		}
+		err := csvWriter.Write([]string{row.RepositoryName, row.RepositoryID, row.Visibility, row.Username, row.AccessLevel, repoOwner, "", "", "", ""})
+		if err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// CreateReviewDecisionList converts the lines of a review workbook into
+// decisions, in the same way as the add and remove imports.
+func (g *APIGetter) CreateReviewDecisionList(filedata [][]string) ([]data.ReviewDecision, error) {
+	var decisions []data.ReviewDecision
+	columns, records := importColumns(filedata)
+	if err := requireColumns(columns, "repositoryname", "username", "decision"); err != nil {
+		return nil, err
+	}
+	for _, each := range records {
+		var decision data.ReviewDecision
+		decision.RepositoryName = columnValue(each, columns, "repositoryname")
+		decision.Username = columnValue(each, columns, "username")
+		decision.Permission = columnValue(each, columns, "accesslevel")
+		decision.Decision = strings.ToLower(columnValue(each, columns, "decision"))
+		decision.NewAccessLevel = columnValue(each, columns, "newaccesslevel")
+		decision.Comment = columnValue(each, columns, "comment")
+		decision.Owner = columnValue(each, columns, "owner")
+		decision.Reviewer = columnValue(each, columns, "reviewer")
+		decisions = append(decisions, decision)
+	}
+	return decisions, nil
+}
+
+// ValidateReviewDecision checks a non-empty decision can be applied.
+func ValidateReviewDecision(decision data.ReviewDecision) error {
+	switch decision.Decision {
+	case DecisionKeep, DecisionRevoke:
+		return nil
+	case DecisionDowngrade:
+		if PermissionRank(decision.NewAccessLevel) == 0 {
+			return fmt.Errorf("downgrade requires a valid NewAccessLevel, got %q", decision.NewAccessLevel)
+		}
+		if decision.Permission != "" && PermissionRank(decision.NewAccessLevel) >= PermissionRank(decision.Permission) {
+			return fmt.Errorf("NewAccessLevel %s is not lower than %s", decision.NewAccessLevel, decision.Permission)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown decision %q, must be keep, revoke or downgrade", decision.Decision)
+}
+
+// AuditLog appends the outcome of applied review decisions to a CSV file,
+// writing the header when the file is new.
+type AuditLog struct {
+	file      *os.File
+	csvWriter *csv.Writer
+}
+
+func OpenAuditLog(fileName string) (*AuditLog, error) {
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	audit := &AuditLog{file: f, csvWriter: csv.NewWriter(f)}
+	if info.Size() == 0 {
+		err = audit.csvWriter.Write([]string{"Timestamp", "Reviewer", "RepositoryName", "Username", "AccessLevel", "Decision", "NewAccessLevel", "Outcome", "Error", "Comment"})
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return audit, nil
+}
+
+func (a *AuditLog) Record(reviewer string, decision data.ReviewDecision, outcome string, errMessage string) error {
+	return a.csvWriter.Write([]string{
+		time.Now().UTC().Format(time.RFC3339),
+		reviewer,
+		decision.RepositoryName,
+		decision.Username,
+		decision.Permission,
+		decision.Decision,
+		decision.NewAccessLevel,
+		outcome,
+		errMessage,
+		decision.Comment,
+	})
+}
+
+func (a *AuditLog) Close() error {
+	a.csvWriter.Flush()
+	if err := a.csvWriter.Error(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
+
+func WriteReviewManifest(w io.Writer, manifest data.ReviewManifest) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+func ReadReviewManifest(fileName string) (data.ReviewManifest, error) {
+	var manifest data.ReviewManifest
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return manifest, err
+	}
+	err = json.Unmarshal(content, &manifest)
+	return manifest, err
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+func TestValidateReviewDecision(t *testing.T) {
+	tests := []struct {
+		name           string
+		decision       string
+		permission     string
+		newAccessLevel string
+		wantErr        bool
+	}{
+		{name: "keep", decision: DecisionKeep, permission: "WRITE"},
+		{name: "revoke", decision: DecisionRevoke, permission: "ADMIN"},
+		{name: "downgrade", decision: DecisionDowngrade, permission: "ADMIN", newAccessLevel: "read"},
+		{name: "downgrade without a current access level", decision: DecisionDowngrade, newAccessLevel: "TRIAGE"},
+		{name: "downgrade without a new access level", decision: DecisionDowngrade, permission: "ADMIN", wantErr: true},
+		{name: "downgrade to an unknown access level", decision: DecisionDowngrade, permission: "ADMIN", newAccessLevel: "owner", wantErr: true},
+		{name: "downgrade to the same access level", decision: DecisionDowngrade, permission: "WRITE", newAccessLevel: "WRITE", wantErr: true},
+		{name: "downgrade to a higher access level", decision: DecisionDowngrade, permission: "READ", newAccessLevel: "MAINTAIN", wantErr: true},
+		{name: "unknown decision", decision: "approve", permission: "WRITE", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := data.ReviewDecision{
+				ImportedRepoCollab: data.ImportedRepoCollab{RepositoryName: "api", Username: "alice", Permission: tt.permission},
+				Decision:           tt.decision,
+				NewAccessLevel:     tt.newAccessLevel,
+			}
+			err := ValidateReviewDecision(decision)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateReviewDecision() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
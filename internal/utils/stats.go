@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+// ComputeStats aggregates the repository grants of a report, ignoring team
+// membership rows, keeping the top guests and repositories by count.
+func ComputeStats(owner string, rows []data.ReportRow, top int) data.Stats {
+	stats := data.Stats{Organization: owner, StatsTotals: computeTotals(rows)}
+	byPermission := make(map[string]int)
+	byVisibility := make(map[string]int)
+	byGuest := make(map[string]int)
+	byRepo := make(map[string]int)
+	guestNames := make(map[string]string)
+	repoNames := make(map[string]string)
+	for _, row := range rows {
+		if row.Team != "" {
+			continue
+		}
+		byPermission[strings.ToUpper(row.AccessLevel)]++
+		byVisibility[strings.ToLower(row.Visibility)]++
+		// counted case-insensitively, as in the totals, under the first
+		// spelling seen
+		byGuest[firstSpelling(guestNames, row.Username)]++
+		byRepo[firstSpelling(repoNames, row.RepositoryName)]++
+	}
+	stats.ByPermission = sortedCounts(byPermission, 0)
+	sort.SliceStable(stats.ByPermission, func(i, j int) bool {
+		return PermissionRank(stats.ByPermission[i].Name) > PermissionRank(stats.ByPermission[j].Name)
+	})
+	stats.ByVisibility = sortedCounts(byVisibility, 0)
+	stats.TopGuests = sortedCounts(byGuest, top)
+	stats.TopRepositories = sortedCounts(byRepo, top)
+	return stats
+}
+
+// firstSpelling returns the first spelling recorded in names of a name
+// compared case-insensitively, recording it when it is new.
+func firstSpelling(names map[string]string, name string) string {
+	key := strings.ToLower(name)
+	if spelling, ok := names[key]; ok {
+		return spelling
+	}
+	names[key] = name
+	return name
+}
+
+func computeTotals(rows []data.ReportRow) data.StatsTotals {
+	var totals data.StatsTotals
+	guests := make(map[string]bool)
+	writers := make(map[string]bool)
+	repos := make(map[string]bool)
+	for _, row := range rows {
+		if row.Team != "" {
+			continue
+		}
+		totals.Grants++
+		guests[strings.ToLower(row.Username)] = true
+		repos[strings.ToLower(row.RepositoryName)] = true
+		if PermissionRank(row.AccessLevel) >= PermissionRank("write") {
+			totals.WriteGrants++
+			writers[strings.ToLower(row.Username)] = true
+		}
+	}
+	totals.Guests = len(guests)
+	totals.Repositories = len(repos)
+	totals.GuestsWithWrite = len(writers)
+	return totals
+}
+
+// CompareStats records the totals of a previous report to show the trend.
+func CompareStats(stats *data.Stats, previousRows []data.ReportRow) {
+	previous := computeTotals(previousRows)
+	stats.Previous = &previous
+}
+
+// sortedCounts orders counts from highest to lowest, then by name, keeping
+// the first top entries when top is positive.
+func sortedCounts(counts map[string]int, top int) []data.StatsCount {
+	sorted := make([]data.StatsCount, 0, len(counts))
+	for name, count := range counts {
+		sorted = append(sorted, data.StatsCount{Name: name, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	if top > 0 && len(sorted) > top {
+		sorted = sorted[:top]
+	}
+	return sorted
+}
+
+type statsTotal struct {
+	label    string
+	current  int
+	previous int
+}
+
+func statsTotals(stats data.Stats) []statsTotal {
+	var previous data.StatsTotals
+	if stats.Previous != nil {
+		previous = *stats.Previous
+	}
+	return []statsTotal{
+		{"Guests", stats.Guests, previous.Guests},
+		{"Repositories with guests", stats.Repositories, previous.Repositories},
+		{"Grants", stats.Grants, previous.Grants},
+		{"Grants with write or higher", stats.WriteGrants, previous.WriteGrants},
+		{"Guests with write or higher", stats.GuestsWithWrite, previous.GuestsWithWrite},
+	}
+}
+
+func trend(current int, previous int) string {
+	return fmt.Sprintf("%+d", current-previous)
+}
+
+// WriteStatsText writes the statistics as aligned sections.
+func WriteStatsText(w io.Writer, stats data.Stats) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Repository collaborators in %s:\n", stats.Organization)
+	for _, total := range statsTotals(stats) {
+		if stats.Previous != nil {
+			fmt.Fprintf(tw, "  %s\t%d\t(%s)\n", total.label, total.current, trend(total.current, total.previous))
+		} else {
+			fmt.Fprintf(tw, "  %s\t%d\n", total.label, total.current)
+		}
+	}
+	sections := []struct {
+		heading string
+		counts  []data.StatsCount
+	}{
+		{"Grants by access level", stats.ByPermission},
+		{"Grants by repository visibility", stats.ByVisibility},
+		{"Top guests by repository count", stats.TopGuests},
+		{"Repositories with the most guests", stats.TopRepositories},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(tw, "\n%s:\n", section.heading)
+		for _, count := range section.counts {
+			fmt.Fprintf(tw, "  %s\t%d\n", count.Name, count.Count)
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteStatsMarkdown writes the statistics as Markdown tables.
+func WriteStatsMarkdown(w io.Writer, stats data.Stats) error {
+	fmt.Fprintf(w, "# Repository collaborators in %s\n\n", stats.Organization)
+	if stats.Previous != nil {
+		fmt.Fprintln(w, "| Total | Count | Change |")
+		fmt.Fprintln(w, "|:------|------:|-------:|")
+	} else {
+		fmt.Fprintln(w, "| Total | Count |")
+		fmt.Fprintln(w, "|:------|------:|")
+	}
+	for _, total := range statsTotals(stats) {
+		if stats.Previous != nil {
+			fmt.Fprintf(w, "| %s | %d | %s |\n", total.label, total.current, trend(total.current, total.previous))
+		} else {
+			fmt.Fprintf(w, "| %s | %d |\n", total.label, total.current)
+		}
+	}
+	sections := []struct {
+		heading string
+		column  string
+		counts  []data.StatsCount
+	}{
+		{"Grants by access level", "Access Level", stats.ByPermission},
+		{"Grants by repository visibility", "Visibility", stats.ByVisibility},
+		{"Top guests by repository count", "Username", stats.TopGuests},
+		{"Repositories with the most guests", "Repository", stats.TopRepositories},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(w, "\n## %s\n\n| %s | Count |\n|:---|---:|\n", section.heading, section.column)
+		for _, count := range section.counts {
+			fmt.Fprintf(w, "| %s | %d |\n", count.Name, count.Count)
+		}
+	}
+	return nil
+}
+
+func WriteStatsJSON(w io.Writer, stats data.Stats) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+)
+
+func TestComputeStats(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []data.ReportRow
+		top  int
+		want data.Stats
+	}{
+		{
+			name: "empty report",
+			want: data.Stats{
+				Organization:    "acme",
+				ByPermission:    []data.StatsCount{},
+				ByVisibility:    []data.StatsCount{},
+				TopGuests:       []data.StatsCount{},
+				TopRepositories: []data.StatsCount{},
+			},
+		},
+		{
+			name: "grants are counted and team rows ignored",
+			rows: []data.ReportRow{
+				{RepositoryName: "api", Visibility: "private", Username: "alice", AccessLevel: "ADMIN"},
+				{RepositoryName: "docs", Visibility: "Public", Username: "alice", AccessLevel: "read"},
+				{RepositoryName: "api", Visibility: "private", Username: "bob", AccessLevel: "WRITE"},
+				{Team: "eng", Username: "carol", TeamRole: "member"},
+			},
+			want: data.Stats{
+				Organization:    "acme",
+				StatsTotals:     data.StatsTotals{Guests: 2, Repositories: 2, Grants: 3, WriteGrants: 2, GuestsWithWrite: 2},
+				ByPermission:    []data.StatsCount{{Name: "ADMIN", Count: 1}, {Name: "WRITE", Count: 1}, {Name: "READ", Count: 1}},
+				ByVisibility:    []data.StatsCount{{Name: "private", Count: 2}, {Name: "public", Count: 1}},
+				TopGuests:       []data.StatsCount{{Name: "alice", Count: 2}, {Name: "bob", Count: 1}},
+				TopRepositories: []data.StatsCount{{Name: "api", Count: 2}, {Name: "docs", Count: 1}},
+			},
+		},
+		{
+			name: "guests and repositories are counted case-insensitively",
+			rows: []data.ReportRow{
+				{RepositoryName: "API", Visibility: "private", Username: "Alice", AccessLevel: "READ"},
+				{RepositoryName: "api", Visibility: "private", Username: "bob", AccessLevel: "READ"},
+				{RepositoryName: "docs", Visibility: "private", Username: "alice", AccessLevel: "READ"},
+			},
+			want: data.Stats{
+				Organization:    "acme",
+				StatsTotals:     data.StatsTotals{Guests: 2, Repositories: 2, Grants: 3},
+				ByPermission:    []data.StatsCount{{Name: "READ", Count: 3}},
+				ByVisibility:    []data.StatsCount{{Name: "private", Count: 3}},
+				TopGuests:       []data.StatsCount{{Name: "Alice", Count: 2}, {Name: "bob", Count: 1}},
+				TopRepositories: []data.StatsCount{{Name: "API", Count: 2}, {Name: "docs", Count: 1}},
+			},
+		},
+		{
+			name: "top limits guests and repositories",
+			rows: []data.ReportRow{
+				{RepositoryName: "api", Visibility: "private", Username: "carol", AccessLevel: "TRIAGE"},
+				{RepositoryName: "docs", Visibility: "private", Username: "bob", AccessLevel: "TRIAGE"},
+				{RepositoryName: "web", Visibility: "private", Username: "alice", AccessLevel: "TRIAGE"},
+			},
+			top: 2,
+			want: data.Stats{
+				Organization:    "acme",
+				StatsTotals:     data.StatsTotals{Guests: 3, Repositories: 3, Grants: 3},
+				ByPermission:    []data.StatsCount{{Name: "TRIAGE", Count: 3}},
+				ByVisibility:    []data.StatsCount{{Name: "private", Count: 3}},
+				TopGuests:       []data.StatsCount{{Name: "alice", Count: 1}, {Name: "bob", Count: 1}},
+				TopRepositories: []data.StatsCount{{Name: "api", Count: 1}, {Name: "docs", Count: 1}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeStats("acme", tt.rows, tt.top)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ComputeStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
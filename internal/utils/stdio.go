@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"io"
+	"os"
+)
+
+// StdioFileName is the file name that reads from stdin or writes to stdout.
+const StdioFileName = "-"
+
+// OpenInput opens a file for reading, or stdin for StdioFileName.
+func OpenInput(fileName string) (io.ReadCloser, error) {
+	if fileName == StdioFileName {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(fileName)
+}
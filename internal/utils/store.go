@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	snapshotStoreFile = "collaborators.db"
+	snapshotKeyFormat = "2006-01-02T15:04:05.000000000Z"
+)
+
+// SnapshotStore persists list reports in a single database file, with one
+// bucket per organization and one entry per run keyed by its UTC time.
+type SnapshotStore struct {
+	db *bolt.DB
+}
+
+func OpenSnapshotStore(dir string) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(filepath.Join(dir, snapshotStoreFile), 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotStore{db: db}, nil
+}
+
+// ReadSnapshotStore opens an existing store for reading, failing rather than
+// creating an empty store when the directory holds none, such as when it is
+// mistyped.
+func ReadSnapshotStore(dir string) (*SnapshotStore, error) {
+	fileName := filepath.Join(dir, snapshotStoreFile)
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, fmt.Errorf("no snapshot store found in %s: %w", dir, err)
+	}
+	db, err := bolt.Open(fileName, 0644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotStore{db: db}, nil
+}
+
+func (s *SnapshotStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SnapshotStore) SaveSnapshot(owner string, takenAt time.Time, rows []data.ReportRow) error {
+	value, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(strings.ToLower(owner)))
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(takenAt.UTC().Format(snapshotKeyFormat)), value)
+	})
+}
+
+// Snapshots returns every stored snapshot of the organization, oldest first.
+func (s *SnapshotStore) Snapshots(owner string) ([]data.Snapshot, error) {
+	var snapshots []data.Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(strings.ToLower(owner)))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(key []byte, value []byte) error {
+			takenAt, err := time.Parse(snapshotKeyFormat, string(key))
+			if err != nil {
+				return err
+			}
+			snapshot := data.Snapshot{TakenAt: takenAt}
+			if err = json.Unmarshal(value, &snapshot.Rows); err != nil {
+				return err
+			}
+			snapshots = append(snapshots, snapshot)
+			return nil
+		})
+	})
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].TakenAt.Before(snapshots[j].TakenAt)
+	})
+	return snapshots, err
+}
+
+// SnapshotAt returns the latest snapshot taken at or before the given time.
+func SnapshotAt(snapshots []data.Snapshot, at time.Time) (data.Snapshot, bool) {
+	found := false
+	var snapshot data.Snapshot
+	for _, s := range snapshots {
+		if s.TakenAt.After(at) {
+			break
+		}
+		snapshot, found = s, true
+	}
+	return snapshot, found
+}
+
+// SnapshotHistory diffs each snapshot against the one before it, the first
+// snapshot reporting all of its grants as added.
+func SnapshotHistory(snapshots []data.Snapshot) []data.HistoryEvent {
+	var events []data.HistoryEvent
+	var previous []data.ReportRow
+	for _, snapshot := range snapshots {
+		for _, change := range DiffReports(previous, snapshot.Rows) {
+			events = append(events, data.HistoryEvent{Date: snapshot.TakenAt, ReportChange: change})
+		}
+		previous = snapshot.Rows
+	}
+	return events
+}
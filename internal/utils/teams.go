@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/shurcooL/graphql"
+	"go.uber.org/zap"
+)
+
+func (g *APIGetter) GetOrgTeams(owner string) ([]data.Team, error) {
+	var teams []data.Team
+	url := fmt.Sprintf("orgs/%s/teams?per_page=100", owner)
+	zap.S().Debugf("Reading in teams from %v", url)
+	err := g.GetPaginated(url, func(body []byte) error {
+		var page []data.Team
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		teams = append(teams, page...)
+		return nil
+	})
+	return teams, err
+}
+
+// GetTeamMembers lists the members of a team along with their team role.
+func (g *APIGetter) GetTeamMembers(owner string, team string) ([]data.TeamMember, error) {
+	var members []data.TeamMember
+	for _, role := range []string{"maintainer", "member"} {
+		url := fmt.Sprintf("orgs/%s/teams/%s/members?role=%s&per_page=100", owner, team, role)
+		err := g.GetPaginated(url, func(body []byte) error {
+			var page []data.TeamMember
+			if err := json.Unmarshal(body, &page); err != nil {
+				return err
+			}
+			for _, member := range page {
+				member.Role = role
+				members = append(members, member)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return members, nil
+}
+
+func (g *APIGetter) AddTeamMembership(owner string, team string, username string, role string) error {
+	url := fmt.Sprintf("orgs/%s/teams/%s/memberships/%s", owner, team, username)
+	body, err := json.Marshal(data.TeamRole{Role: role})
+	if err != nil {
+		return err
+	}
+	resp, err := g.restClient.Request("PUT", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+func (g *APIGetter) RemoveTeamMembership(owner string, team string, username string) error {
+	url := fmt.Sprintf("orgs/%s/teams/%s/memberships/%s", owner, team, username)
+	resp, err := g.restClient.Request("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// TeamMembershipRole converts the TeamRole of an imported row to a team
+// membership role, defaulting to member when it is empty.
+func TeamMembershipRole(teamRole string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(teamRole)) {
+	case "", "member":
+		return "member", nil
+	case "maintainer":
+		return "maintainer", nil
+	}
+	return "", fmt.Errorf("invalid team role %q, must be member or maintainer", teamRole)
+}
+
+func (g *APIGetter) GetTeam(owner string, team string) (*data.Team, error) {
+	url := fmt.Sprintf("orgs/%s/teams/%s", owner, team)
+	var teamData data.Team
+	err := g.restClient.Get(url, &teamData)
+	return &teamData, err
+}
+
+// GetTeamRepoPermissions returns the permission the team holds on each of its
+// repositories, keyed by repository name.
+func (g *APIGetter) GetTeamRepoPermissions(owner string, team string) (map[string]string, error) {
+	var reposCursor *string
+	permissions := make(map[string]string)
+	for {
+		query := new(data.TeamRepositoriesQuery)
+		variables := map[string]interface{}{
+			"endCursor": (*graphql.String)(reposCursor),
+			"owner":     graphql.String(owner),
+			"slug":      graphql.String(team),
+		}
+		err := g.gqlClient.Query("getTeamRepoPermissions", &query, variables)
+		if err != nil {
+			return permissions, err
+		}
+		repositories := query.Organization.Team.Repositories
+		for _, edge := range repositories.Edges {
+			permissions[edge.Node.Name] = edge.Permission
+		}
+		if !repositories.PageInfo.HasNextPage {
+			break
+		}
+		reposCursor = &repositories.PageInfo.EndCursor
+	}
+	return permissions, nil
+}
+
+func (g *APIGetter) AddTeamRepoPermission(owner string, team string, repo string, permission string) error {
+	url := fmt.Sprintf("orgs/%s/teams/%s/repos/%s/%s", owner, team, owner, repo)
+	body, err := json.Marshal(CreateRepoPermData(permission))
+	if err != nil {
+		return err
+	}
+	resp, err := g.restClient.Request("PUT", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TwoFactorState records when each repository collaborator was first seen
+// without two-factor authentication, so the grace period before removal
+// survives between runs.
+type TwoFactorState struct {
+	Organization string               `json:"organization"`
+	FirstSeen    map[string]time.Time `json:"firstSeen"`
+}
+
+// TwoFactorDeadline is a non-compliant repository collaborator and when they
+// will be removed.
+type TwoFactorDeadline struct {
+	Username  string
+	FirstSeen time.Time
+	Deadline  time.Time
+}
+
+// LoadTwoFactorState reads the state file, returning an empty state when it
+// does not exist yet. A state file of another organization is an error, as
+// its first seen times would be applied to the wrong users.
+func LoadTwoFactorState(fileName string, owner string) (*TwoFactorState, error) {
+	state := &TwoFactorState{Organization: owner, FirstSeen: make(map[string]time.Time)}
+	content, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, state); err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(state.Organization, owner) {
+		return nil, fmt.Errorf("state file %s tracks organization %s, not %s", fileName, state.Organization, owner)
+	}
+	if state.FirstSeen == nil {
+		state.FirstSeen = make(map[string]time.Time)
+	}
+	return state, nil
+}
+
+// Save writes the state file atomically, as a partially written file would
+// lose every first seen time and restart every grace period.
+func (s *TwoFactorState) Save(fileName string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := CreateAtomic(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if _, err = f.Write(append(content, '\n')); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// Update starts tracking newly non-compliant users from now and forgets users
+// that have since enabled two-factor authentication or left, returning the
+// deadline of each non-compliant user, earliest first.
+func (s *TwoFactorState) Update(disabled map[string]bool, now time.Time, grace time.Duration) []TwoFactorDeadline {
+	current := make(map[string]bool, len(disabled))
+	for login := range disabled {
+		current[strings.ToLower(login)] = true
+	}
+	for login := range s.FirstSeen {
+		if !current[login] {
+			delete(s.FirstSeen, login)
+		}
+	}
+
+	var deadlines []TwoFactorDeadline
+	for login := range disabled {
+		key := strings.ToLower(login)
+		firstSeen, ok := s.FirstSeen[key]
+		if !ok {
+			firstSeen = now
+			s.FirstSeen[key] = now
+		}
+		deadlines = append(deadlines, TwoFactorDeadline{Username: login, FirstSeen: firstSeen, Deadline: firstSeen.Add(grace)})
+	}
+	sort.Slice(deadlines, func(i, j int) bool {
+		if !deadlines[i].Deadline.Equal(deadlines[j].Deadline) {
+			return deadlines[i].Deadline.Before(deadlines[j].Deadline)
+		}
+		return deadlines[i].Username < deadlines[j].Username
+	})
+	return deadlines
+}
+
+// Forget stops tracking a user, such as once they have been removed.
+func (s *TwoFactorState) Forget(login string) {
+	delete(s.FirstSeen, strings.ToLower(login))
+}
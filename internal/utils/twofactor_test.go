@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTwoFactorStateUpdate(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-5 * 24 * time.Hour)
+	grace := 7 * 24 * time.Hour
+	tests := []struct {
+		name          string
+		firstSeen     map[string]time.Time
+		disabled      map[string]bool
+		want          []TwoFactorDeadline
+		wantFirstSeen map[string]time.Time
+	}{
+		{
+			name:          "newly seen users start their grace period now",
+			firstSeen:     map[string]time.Time{},
+			disabled:      map[string]bool{"bob": true, "Alice": true},
+			want:          []TwoFactorDeadline{{Username: "Alice", FirstSeen: now, Deadline: now.Add(grace)}, {Username: "bob", FirstSeen: now, Deadline: now.Add(grace)}},
+			wantFirstSeen: map[string]time.Time{"alice": now, "bob": now},
+		},
+		{
+			name:          "tracked users keep their first seen time",
+			firstSeen:     map[string]time.Time{"alice": earlier},
+			disabled:      map[string]bool{"ALICE": true, "bob": true},
+			want:          []TwoFactorDeadline{{Username: "ALICE", FirstSeen: earlier, Deadline: earlier.Add(grace)}, {Username: "bob", FirstSeen: now, Deadline: now.Add(grace)}},
+			wantFirstSeen: map[string]time.Time{"alice": earlier, "bob": now},
+		},
+		{
+			name:          "compliant users are forgotten",
+			firstSeen:     map[string]time.Time{"alice": earlier, "carol": earlier},
+			disabled:      map[string]bool{"alice": true},
+			want:          []TwoFactorDeadline{{Username: "alice", FirstSeen: earlier, Deadline: earlier.Add(grace)}},
+			wantFirstSeen: map[string]time.Time{"alice": earlier},
+		},
+		{
+			name:          "everyone compliant",
+			firstSeen:     map[string]time.Time{"alice": earlier},
+			disabled:      map[string]bool{},
+			wantFirstSeen: map[string]time.Time{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &TwoFactorState{Organization: "acme", FirstSeen: tt.firstSeen}
+			got := state.Update(tt.disabled, now, grace)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Update() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(state.FirstSeen, tt.wantFirstSeen) {
+				t.Errorf("Update() first seen = %v, want %v", state.FirstSeen, tt.wantFirstSeen)
+			}
+		})
+	}
+}
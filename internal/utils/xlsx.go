@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-collaborators/internal/data"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	SheetByUser       = "By User"
+	SheetByRepository = "By Repository"
+	SheetSummary      = "Summary"
+	SheetInvitations  = "Invitations"
+)
+
+// IsXLSXFile reports whether a file name has the xlsx extension.
+func IsXLSXFile(fileName string) bool {
+	return strings.EqualFold(filepath.Ext(fileName), ".xlsx")
+}
+
+// WriteReportXLSX writes the report as a workbook with the grants sorted by
+// user and by repository, a summary and the pending repository invitations.
+// Every sheet has a frozen header row and an autofilter, and numeric columns
+// are written as numbers.
+func WriteReportXLSX(w io.Writer, owner string, columnNames []string, rows []data.ReportRow, invitations []data.RepoInvitation) error {
+	var columns []ReportColumn
+	for _, name := range columnNames {
+		column, ok := reportColumn(name)
+		if !ok {
+			return fmt.Errorf("unknown report column %s", name)
+		}
+		columns = append(columns, column)
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}},
+	})
+	if err != nil {
+		return err
+	}
+
+	byUser := append([]data.ReportRow{}, rows...)
+	sort.SliceStable(byUser, func(i, j int) bool {
+		if !strings.EqualFold(byUser[i].Username, byUser[j].Username) {
+			return strings.ToLower(byUser[i].Username) < strings.ToLower(byUser[j].Username)
+		}
+		return byUser[i].RepositoryName < byUser[j].RepositoryName
+	})
+	byRepo := append([]data.ReportRow{}, rows...)
+	sort.SliceStable(byRepo, func(i, j int) bool {
+		if byRepo[i].RepositoryName != byRepo[j].RepositoryName {
+			return byRepo[i].RepositoryName < byRepo[j].RepositoryName
+		}
+		return strings.ToLower(byRepo[i].Username) < strings.ToLower(byRepo[j].Username)
+	})
+
+	sheets := []struct {
+		name    string
+		header  []string
+		records [][]interface{}
+	}{
+		{SheetByUser, columnNames, reportRecords(columns, byUser)},
+		{SheetByRepository, columnNames, reportRecords(columns, byRepo)},
+		{SheetSummary, []string{"Statistic", "Name", "Count"}, summaryRecords(ComputeStats(owner, rows, 0))},
+		{SheetInvitations, []string{"RepositoryName", "Username", "AccessLevel", "CreatedAt"}, invitationRecords(invitations)},
+	}
+	for i, sheet := range sheets {
+		if i == 0 {
+			err = f.SetSheetName("Sheet1", sheet.name)
+		} else {
+			_, err = f.NewSheet(sheet.name)
+		}
+		if err != nil {
+			return err
+		}
+		if err = writeSheet(f, sheet.name, sheet.header, sheet.records, headerStyle); err != nil {
+			return err
+		}
+	}
+	return f.Write(w)
+}
+
+func writeSheet(f *excelize.File, sheet string, header []string, records [][]interface{}, headerStyle int) error {
+	headerRow := make([]interface{}, len(header))
+	for i, name := range header {
+		headerRow[i] = name
+	}
+	if err := f.SetSheetRow(sheet, "A1", &headerRow); err != nil {
+		return err
+	}
+	for i, record := range records {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err = f.SetSheetRow(sheet, cell, &record); err != nil {
+			return err
+		}
+	}
+
+	lastCell, err := excelize.CoordinatesToCellName(len(header), len(records)+1)
+	if err != nil {
+		return err
+	}
+	lastHeader, err := excelize.CoordinatesToCellName(len(header), 1)
+	if err != nil {
+		return err
+	}
+	if err = f.SetCellStyle(sheet, "A1", lastHeader, headerStyle); err != nil {
+		return err
+	}
+	lastColumn, _, err := excelize.SplitCellName(lastHeader)
+	if err != nil {
+		return err
+	}
+	if err = f.SetColWidth(sheet, "A", lastColumn, 20); err != nil {
+		return err
+	}
+	if err = f.AutoFilter(sheet, "A1:"+lastCell, nil); err != nil {
+		return err
+	}
+	return f.SetPanes(sheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	})
+}
+
+// reportRecords converts rows to sheet records, writing repository IDs as
+// numbers and everything else as text.
+func reportRecords(columns []ReportColumn, rows []data.ReportRow) [][]interface{} {
+	records := make([][]interface{}, 0, len(rows))
+	for i := range rows {
+		record := make([]interface{}, len(columns))
+		for j, column := range columns {
+			value := column.Get(&rows[i])
+			if id, err := strconv.Atoi(value); err == nil && column.Name == "RepositoryID" {
+				record[j] = id
+			} else {
+				record[j] = value
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func summaryRecords(stats data.Stats) [][]interface{} {
+	records := [][]interface{}{
+		{"Total", "Guests", stats.Guests},
+		{"Total", "Repositories with guests", stats.Repositories},
+		{"Total", "Grants", stats.Grants},
+		{"Total", "Grants with write or higher", stats.WriteGrants},
+		{"Total", "Guests with write or higher", stats.GuestsWithWrite},
+	}
+	for _, count := range stats.ByPermission {
+		records = append(records, []interface{}{"Access level", count.Name, count.Count})
+	}
+	for _, count := range stats.ByVisibility {
+		records = append(records, []interface{}{"Visibility", count.Name, count.Count})
+	}
+	return records
+}
+
+func invitationRecords(invitations []data.RepoInvitation) [][]interface{} {
+	records := make([][]interface{}, 0, len(invitations))
+	for _, invitation := range invitations {
+		record := []interface{}{invitation.Repository.Name, invitation.Invitee.Login, invitation.Permission, ""}
+		if !invitation.CreatedAt.IsZero() {
+			record[3] = invitation.CreatedAt
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// ReadXLSXRecords reads the rows of the By User sheet of a workbook written by
+// list, or of the first sheet of any other workbook, in the same form as a
+// csv file.
+func ReadXLSXRecords(fileName string) ([][]string, error) {
+	f, err := excelize.OpenFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sheet := f.GetSheetName(0)
+	if index, err := f.GetSheetIndex(SheetByUser); err == nil && index >= 0 {
+		sheet = SheetByUser
+	}
+	records, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("sheet %s of %s is empty", sheet, fileName)
+	}
+	return records, nil
+}